@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+)
+
+// backupSuffix is appended to a container's name while it is kept aside
+// during an update.
+const backupSuffix = "_drun_backup"
+
+func backupName(containerName string) string {
+	return containerName + backupSuffix
+}
+
+func containerExists(name string) bool {
+	return dockerCommand("container", "inspect", name).Run() == nil
+}
+
+// backupContainer moves the stopped old container out of the way under its
+// backup name, so the new one can take the name while the old one stays
+// around to fall back to.
+func backupContainer(containerName string) (string, error) {
+	backup := backupName(containerName)
+	if containerExists(backup) {
+		return "", fmt.Errorf("%s already exists, left over from an interrupted update; remove it or rename it back to %s", backup, containerName)
+	}
+	printInfo("Renaming container %s to %s...\n", containerName, backup)
+	if err := dockerCommand("rename", containerName, backup).Run(); err != nil {
+		return "", fmt.Errorf("failed to rename container: %v", err)
+	}
+	return backup, nil
+}
+
+// restoreBackup puts the old container back after a failed update: any new
+// container holding the name is removed, the backup gets its name back and
+// is started again if it was running.
+func restoreBackup(containerName, backup string, start bool) error {
+	printWarning("Restoring %s from %s...\n", containerName, backup)
+	if containerExists(containerName) {
+		if err := dockerCommand("rm", "-f", containerName).Run(); err != nil {
+			return fmt.Errorf("failed to remove the new container: %v", err)
+		}
+	}
+	if err := dockerCommand("rename", backup, containerName).Run(); err != nil {
+		return fmt.Errorf("failed to rename %s back: %v", backup, err)
+	}
+	if start {
+		if err := dockerCommand("start", containerName).Run(); err != nil {
+			return fmt.Errorf("failed to start %s: %v", containerName, err)
+		}
+	}
+	return nil
+}
+
+// removeBackup deletes the old container once its replacement is up.
+func removeBackup(backup string) error {
+	printInfo("Removing container %s...\n", backup)
+	if err := dockerCommand("rm", backup).Run(); err != nil {
+		return fmt.Errorf("failed to remove container: %v", err)
+	}
+	return nil
+}
+
+// writeInspectBackup saves the container's raw docker inspect output to
+// <dir>/<container>-<timestamp>.json, a record of its exact configuration
+// before drun touched it. The file is private to the user since the
+// environment often holds credentials.
+func writeInspectBackup(dir, containerName string, raw json.RawMessage, at time.Time) (string, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", fmt.Errorf("failed to create backup dir: %v", err)
+	}
+	path := filepath.Join(dir, fmt.Sprintf("%s-%s.json", containerName, at.UTC().Format("20060102-150405")))
+	if err := os.WriteFile(path, append(slices.Clip(raw), '\n'), 0o600); err != nil {
+		return "", fmt.Errorf("failed to write inspect backup: %v", err)
+	}
+	return path, nil
+}
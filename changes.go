@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// runBoolFlags are the docker run flags drun emits without a value.
+var runBoolFlags = map[string]bool{
+	"-d": true, "-i": true, "-t": true, "-it": true, "-P": true,
+	"--privileged": true, "--read-only": true, "--init": true, "--rm": true,
+	"--no-healthcheck": true,
+}
+
+// splitRunFlags groups a generated docker run argv into one entry per flag
+// ("-e FOO=bar"), followed by the image and the command.
+func splitRunFlags(argv []string) []string {
+	var entries []string
+	i := 0
+	for i < len(argv) && argv[i] != "run" {
+		i++
+	}
+	for i++; i < len(argv); i++ {
+		arg := argv[i]
+		if !strings.HasPrefix(arg, "-") {
+			entries = append(entries, "image "+arg)
+			if rest := argv[i+1:]; len(rest) > 0 {
+				entries = append(entries, "command "+strings.Join(rest, " "))
+			}
+			break
+		}
+		if runBoolFlags[arg] || i+1 == len(argv) {
+			entries = append(entries, arg)
+			continue
+		}
+		entries = append(entries, arg+" "+argv[i+1])
+		i++
+	}
+	return entries
+}
+
+// describeChanges summarizes how the new run differs from what drun last
+// recorded for the container: the image transition and any added or removed
+// flags. previous is nil when the container was never recreated by drun.
+func describeChanges(oldImage, oldImageID, newImage, newImageID string, previous *containerState, argv []string) []string {
+	var lines []string
+	switch {
+	case oldImage != newImage:
+		lines = append(lines, fmt.Sprintf("image: %s -> %s", oldImage, newImage))
+	case oldImageID != "" && newImageID != "" && oldImageID != newImageID:
+		lines = append(lines, fmt.Sprintf("image: %s (%s -> %s)", newImage, shortID(oldImageID), shortID(newImageID)))
+	default:
+		lines = append(lines, fmt.Sprintf("image: %s (unchanged)", newImage))
+	}
+
+	if previous == nil {
+		return append(lines, "no previous drun run recorded for this container")
+	}
+
+	before := make(map[string]bool)
+	for _, entry := range splitRunFlags(previous.Argv) {
+		before[entry] = true
+	}
+	after := make(map[string]bool)
+	for _, entry := range splitRunFlags(argv) {
+		after[entry] = true
+		if !before[entry] {
+			lines = append(lines, "+ "+entry)
+		}
+	}
+	for _, entry := range splitRunFlags(previous.Argv) {
+		if !after[entry] {
+			lines = append(lines, "- "+entry)
+		}
+	}
+	return lines
+}
+
+func shortID(id string) string {
+	id = strings.TrimPrefix(id, "sha256:")
+	if len(id) > 12 {
+		return id[:12]
+	}
+	return id
+}
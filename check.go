@@ -0,0 +1,80 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+)
+
+// checkStaleExitCode is what `drun check` exits with when an update is
+// available, kept apart from 1 (an error) so CI can tell the two apart.
+const checkStaleExitCode = 3
+
+// runCheck implements `drun check`, which reports whether the given
+// containers run the image their tag currently resolves to in the registry.
+// It only reads and never touches the containers.
+func runCheck(args []string) int {
+	fs := flag.NewFlagSet("drun check", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: drun check [-H URL] [--runtime BINARY] <container_name>...\n\nExits 0 when every image is current, %d when an update is available and 1 on errors.\n", checkStaleExitCode)
+	}
+	addRuntimeFlags(fs)
+	fs.Parse(args)
+	if fs.NArg() < 1 {
+		fs.Usage()
+		return 2
+	}
+
+	code := 0
+	for _, name := range fs.Args() {
+		info, err := getContainerInfo(name)
+		if err != nil {
+			printError("%s: failed to get container info: %v\n", name, err)
+			code = 1
+			continue
+		}
+
+		current, remote, err := imageFreshness(info)
+		if err != nil {
+			printError("%s: %v\n", name, err)
+			code = 1
+			continue
+		}
+		if current {
+			printSuccess("%s: %s is up to date (%s)\n", name, info.Config.Image, remote)
+			continue
+		}
+
+		printWarning("%s: %s has an update available\n", name, info.Config.Image)
+		fmt.Printf("  running:  %s\n", runningDigest(info))
+		fmt.Printf("  registry: %s\n", remote)
+		if code == 0 {
+			code = checkStaleExitCode
+		}
+	}
+	return code
+}
+
+// runningDigest is the registry digest of the image a container runs, or a
+// placeholder when the image was never pulled from a registry.
+func runningDigest(info *ContainerInfo) string {
+	digests, err := localRepoDigests(info.ImageID)
+	if err != nil || len(digests) == 0 {
+		return "unknown (image " + shortID(info.ImageID) + ")"
+	}
+	repo := imageRepository(info.Config.Image)
+	for _, digest := range digests {
+		if name, sum, _ := strings.Cut(digest, "@"); imageRepository(name) == repo {
+			return sum
+		}
+	}
+	_, sum, _ := strings.Cut(digests[0], "@")
+	return sum
+}
+
+// imageRepository strips the tag and digest from an image reference.
+func imageRepository(ref string) string {
+	parsed := parseImageRef(ref)
+	parsed.Tag, parsed.Digest = "", ""
+	return parsed.String()
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheckExitCodes(t *testing.T) {
+	tests := []struct {
+		names []string
+		want  int
+	}{
+		{[]string{"db"}, 0},
+		{[]string{"web"}, checkStaleExitCode},
+		{[]string{"db", "web"}, checkStaleExitCode},
+		{[]string{"cache", "web"}, 1},
+		{[]string{"missing"}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(strings.Join(tt.names, ","), func(t *testing.T) {
+			resetState(t)
+			f := fakeRegistry(t)
+
+			var code int
+			captureStdout(t, func() { code = runCheck(tt.names) })
+			if code != tt.want {
+				t.Errorf("exit code = %d, want %d", code, tt.want)
+			}
+			for _, command := range []string{"pull", "stop", "rename", "run", "rm"} {
+				if f.count("docker", command) > 0 {
+					t.Errorf("drun check called docker %s", command)
+				}
+			}
+		})
+	}
+}
+
+func TestCheckPrintsDigests(t *testing.T) {
+	resetState(t)
+	fakeRegistry(t)
+
+	out := captureStdout(t, func() { runCheck([]string{"web"}) })
+	for _, want := range []string{"running:  sha256:nginx-older", "registry: sha256:nginx-latest"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output has no %q:\n%s", want, out)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+)
+
+const bashCompletion = `_drun() {
+    local cur="${COMP_WORDS[COMP_CWORD]}"
+    case "$cur" in
+        -*) return ;;
+    esac
+    COMPREPLY=($(compgen -W "$(drun __complete 2>/dev/null)" -- "$cur"))
+}
+complete -F _drun drun
+`
+
+const zshCompletion = `#compdef drun
+_drun() {
+    local -a containers
+    containers=(${(f)"$(drun __complete 2>/dev/null)"})
+    _describe 'container' containers
+}
+compdef _drun drun
+`
+
+const fishCompletion = `complete -c drun -f -a '(drun __complete 2>/dev/null)'
+`
+
+// runCompletion implements `drun completion bash|zsh|fish`, printing a script
+// that completes container names through the hidden __complete command.
+func runCompletion(args []string) int {
+	if len(args) != 1 {
+		fmt.Fprintln(os.Stderr, "Usage: drun completion bash|zsh|fish")
+		return 2
+	}
+
+	switch args[0] {
+	case "bash":
+		fmt.Print(bashCompletion)
+	case "zsh":
+		fmt.Print(zshCompletion)
+	case "fish":
+		fmt.Print(fishCompletion)
+	default:
+		fmt.Fprintf(os.Stderr, "unsupported shell %q (expected bash, zsh or fish)\n", args[0])
+		return 2
+	}
+	return 0
+}
+
+// runComplete implements the hidden `drun __complete` command used by the
+// completion scripts: it prints one running container name per line.
+func runComplete(args []string) int {
+	fs := flag.NewFlagSet("drun __complete", flag.ExitOnError)
+	addRuntimeFlags(fs)
+	fs.Parse(args)
+
+	names, err := runningContainerNames()
+	if err != nil {
+		return 1
+	}
+	for _, name := range names {
+		fmt.Println(name)
+	}
+	return 0
+}
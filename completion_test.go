@@ -0,0 +1,54 @@
+package main
+
+import (
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestCompleteListsRunningContainers(t *testing.T) {
+	resetState(t)
+	f := newFakeDocker(t)
+	f.answer("web\ndb\nworker-1\n", "docker", "ps", "--format", "{{.Names}}")
+
+	var code int
+	out := captureStdout(t, func() { code = run([]string{"__complete"}) })
+	if code != 0 {
+		t.Fatalf("exit code = %d", code)
+	}
+	if out != "web\ndb\nworker-1\n" {
+		t.Errorf("__complete printed %q, want one running container per line", out)
+	}
+}
+
+func TestBashCompletion(t *testing.T) {
+	bash, err := exec.LookPath("bash")
+	if err != nil {
+		t.Skip("bash not installed")
+	}
+	resetState(t)
+	f := newFakeDocker(t)
+	f.answer("web\nworker-1\nworker-2\n", "docker", "ps", "--format", "{{.Names}}")
+	names := captureStdout(t, func() { run([]string{"__complete"}) })
+
+	tests := []struct {
+		word string
+		want string
+	}{
+		{"", "web worker-1 worker-2"},
+		{"wo", "worker-1 worker-2"},
+		{"--", ""},
+	}
+	for _, tt := range tests {
+		// drun is stubbed with what __complete printed for the fake listing.
+		script := "drun() { printf '%s' '" + names + "'; }\n" + bashCompletion +
+			`COMP_WORDS=(drun "` + tt.word + `"); COMP_CWORD=1; _drun; echo "${COMPREPLY[*]}"`
+		out, err := exec.Command(bash, "--norc", "-c", script).Output()
+		if err != nil {
+			t.Fatalf("bash: %v", err)
+		}
+		if got := strings.TrimSpace(string(out)); got != tt.want {
+			t.Errorf("completing %q = %q, want %q", tt.word, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Labels docker compose puts on the containers it manages.
+const (
+	composeProjectLabel = "com.docker.compose.project"
+	composeServiceLabel = "com.docker.compose.service"
+)
+
+// defaultProfileLabel is the label --profile-label reads compose profiles
+// from by default.
+const defaultProfileLabel = "drun.profile"
+
+// exportCompose prints a docker-compose file with one service per container.
+func exportCompose(containerNames []string) error {
+	var infos []*ContainerInfo
+	for _, name := range containerNames {
+		info, err := getContainerInfo(name)
+		if err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+		infos = append(infos, info)
+	}
+	return writeCompose(os.Stdout, infos)
+}
+
+func writeCompose(w io.Writer, infos []*ContainerInfo) error {
+	var b strings.Builder
+
+	// Containers from the same compose project keep its name, so the file
+	// can be used with the original project.
+	if project := composeProject(infos); project != "" {
+		fmt.Fprintf(&b, "name: %s\n", yamlString(project))
+	}
+
+	// Links and --volumes-from may name the container or its ID; both are
+	// resolved to the service of a container exported alongside.
+	services := make(map[string]string)
+	for _, info := range infos {
+		services[strings.TrimPrefix(info.Name, "/")] = composeServiceName(info)
+		services[info.ID] = composeServiceName(info)
+	}
+
+	specs := make([]runSpec, len(infos))
+	b.WriteString("services:\n")
+	for i, info := range infos {
+		specs[i] = newRunSpec(info)
+		writeComposeService(&b, info, specs[i], services)
+	}
+
+	// User-defined networks already exist on the host; marking them
+	// external keeps compose from creating project-scoped copies.
+	if networks := composeNetworks(infos, specs); len(networks) > 0 {
+		b.WriteString("networks:\n")
+		for _, name := range networks {
+			fmt.Fprintf(&b, "  %s:\n    external: true\n", yamlKey(name))
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func writeComposeService(b *strings.Builder, info *ContainerInfo, spec runSpec, services map[string]string) {
+	fmt.Fprintf(b, "  %s:\n", yamlKey(composeServiceName(info)))
+	fmt.Fprintf(b, "    image: %s\n", yamlString(targetImage(info)))
+
+	// Compose generates names like project-service-1 for the containers it
+	// manages; pinning container_name would break that, so only hand-started
+	// containers get one.
+	if info.Config.Labels[composeServiceLabel] == "" {
+		fmt.Fprintf(b, "    container_name: %s\n", yamlString(strings.TrimPrefix(info.Name, "/")))
+	}
+
+	writeYAMLList(b, "profiles", composeProfiles(info))
+	writeYAMLList(b, "depends_on", composeDependencies(info, services))
+	writeYAMLList(b, "command", spec.Command)
+	if spec.Restart != "" {
+		fmt.Fprintf(b, "    restart: %s\n", yamlString(spec.Restart))
+	}
+
+	var ports []string
+	for _, port := range spec.Ports {
+		ports = append(ports, composePort(port))
+	}
+	writeYAMLList(b, "ports", ports)
+
+	var volumes []string
+	for _, volume := range spec.Volumes {
+		value := volume.Source + ":" + volume.Target
+		if volume.ReadOnly {
+			value += ":ro"
+		}
+		volumes = append(volumes, value)
+	}
+	writeYAMLList(b, "volumes", volumes)
+
+	writeYAMLList(b, "environment", spec.Env)
+
+	if mode := info.HostConfig.NetworkMode; composeNetworkMode(mode) {
+		fmt.Fprintf(b, "    network_mode: %s\n", yamlString(mode))
+	} else {
+		writeYAMLList(b, "networks", userNetworks(info, spec))
+	}
+}
+
+// composeProfiles reads the service's profiles from --profile-label.
+func composeProfiles(info *ContainerInfo) []string {
+	var profiles []string
+	for _, profile := range strings.Split(info.Config.Labels[opts.profileLabel], ",") {
+		if profile = strings.TrimSpace(profile); profile != "" {
+			profiles = append(profiles, profile)
+		}
+	}
+	return profiles
+}
+
+// composeDependencies infers depends_on from the containers a container
+// links to or takes volumes from, since both have to exist before it can
+// start. Dependencies that are not part of the export are left out, as
+// compose rejects depends_on entries naming unknown services.
+func composeDependencies(info *ContainerInfo, services map[string]string) []string {
+	var sources []string
+	for _, link := range info.HostConfig.Links {
+		name, _ := parseLink(link)
+		sources = append(sources, name)
+	}
+	for _, source := range info.HostConfig.VolumesFrom {
+		name, _, _ := strings.Cut(source, ":")
+		sources = append(sources, name)
+	}
+
+	self := composeServiceName(info)
+	seen := make(map[string]bool)
+	var dependencies []string
+	for _, source := range sources {
+		service, ok := services[source]
+		if !ok {
+			printWarning("%s depends on %s, which is not being exported; leaving it out of depends_on\n", self, source)
+			continue
+		}
+		if service != self && !seen[service] {
+			seen[service] = true
+			dependencies = append(dependencies, service)
+		}
+	}
+	sort.Strings(dependencies)
+	return dependencies
+}
+
+// composeNetworkMode reports whether a network mode is written as
+// network_mode rather than as a networks entry.
+func composeNetworkMode(mode string) bool {
+	return mode == "host" || mode == "none" || mode == "bridge" || strings.HasPrefix(mode, "container:")
+}
+
+// userNetworks returns the user-defined networks a container is attached
+// to, which compose lists under networks.
+func userNetworks(info *ContainerInfo, spec runSpec) []string {
+	if mode := info.HostConfig.NetworkMode; composeNetworkMode(mode) {
+		return nil
+	}
+	var networks []string
+	for _, name := range spec.Networks {
+		if name != "bridge" {
+			networks = append(networks, name)
+		}
+	}
+	return networks
+}
+
+// composeNetworks collects the user-defined networks of all containers.
+func composeNetworks(infos []*ContainerInfo, specs []runSpec) []string {
+	seen := make(map[string]bool)
+	var networks []string
+	for i, info := range infos {
+		for _, name := range userNetworks(info, specs[i]) {
+			if !seen[name] {
+				seen[name] = true
+				networks = append(networks, name)
+			}
+		}
+	}
+	sort.Strings(networks)
+	return networks
+}
+
+// composePort formats a port in compose's short syntax, which matches
+// docker run's -p value.
+func composePort(port specPort) string {
+	value := port.HostPort + ":" + port.ContainerPort + "/" + port.Protocol
+	switch ip := port.HostIP; {
+	case ip == "" || ip == "0.0.0.0" || ip == "::":
+	case strings.Contains(ip, ":"):
+		value = "[" + ip + "]:" + value
+	default:
+		value = ip + ":" + value
+	}
+	return value
+}
+
+func writeYAMLList(b *strings.Builder, key string, values []string) {
+	if len(values) == 0 {
+		return
+	}
+	fmt.Fprintf(b, "    %s:\n", key)
+	for _, value := range values {
+		fmt.Fprintf(b, "      - %s\n", yamlString(value))
+	}
+}
+
+// composeServiceName is the compose service a container belongs to, falling
+// back to the container name for containers compose did not create.
+func composeServiceName(info *ContainerInfo) string {
+	if service := info.Config.Labels[composeServiceLabel]; service != "" {
+		return service
+	}
+	return strings.TrimPrefix(info.Name, "/")
+}
+
+// composeProject returns the compose project shared by all containers, or ""
+// if they do not all come from the same project.
+func composeProject(infos []*ContainerInfo) string {
+	var project string
+	for i, info := range infos {
+		label := info.Config.Labels[composeProjectLabel]
+		if i > 0 && label != project {
+			return ""
+		}
+		project = label
+	}
+	return project
+}
+
+var yamlPlainKey = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+
+func yamlKey(s string) string {
+	if yamlPlainKey.MatchString(s) {
+		return s
+	}
+	return yamlString(s)
+}
+
+// yamlString always double-quotes scalars. That sidesteps YAML's implicit
+// typing (ports like 53:53 read as base 60, "no" as false) at the cost of
+// slightly noisier output. Compose interpolates $VAR and ${VAR} in every
+// value, so a literal $ is written as $$.
+func yamlString(s string) string {
+	return strings.ReplaceAll(strconv.Quote(s), "$", "$$")
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestComposeServiceFromLabels(t *testing.T) {
+	resetState(t)
+	api := testContainer(t, `"Labels":{"com.docker.compose.project":"shop","com.docker.compose.service":"api"}`, "")
+	api.Name = "/shop-api-1"
+	worker := testContainer(t, `"Labels":{"com.docker.compose.project":"shop","com.docker.compose.service":"worker"}`, "")
+	worker.Name, worker.ID = "/shop-worker-1", "def456"
+
+	var b strings.Builder
+	if err := writeCompose(&b, []*ContainerInfo{api, worker}); err != nil {
+		t.Fatal(err)
+	}
+	out := b.String()
+	for _, want := range []string{"name: \"shop\"\n", "\n  api:\n", "\n  worker:\n"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("compose output has no %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "container_name") || strings.Contains(out, "shop-api-1") {
+		t.Errorf("compose-managed services must not pin container_name:\n%s", out)
+	}
+}
+
+func TestComposeProjectMixed(t *testing.T) {
+	resetState(t)
+	api := testContainer(t, `"Labels":{"com.docker.compose.project":"shop","com.docker.compose.service":"api"}`, "")
+	other := testContainer(t, `"Labels":{"com.docker.compose.project":"blog","com.docker.compose.service":"api"}`, "")
+
+	if got := composeProject([]*ContainerInfo{api, other}); got != "" {
+		t.Errorf("composeProject = %q, want none for containers of different projects", got)
+	}
+	if got := composeServiceName(testContainer(t, "", "")); got != "app" {
+		t.Errorf("composeServiceName = %q, want the container name without a service label", got)
+	}
+}
+
+func TestComposeGolden(t *testing.T) {
+	resetState(t)
+	info := parseInfo(t, strings.Replace(fixture(t, "many-flags"), `"JAVA_OPTS=-Xmx512m -Dfoo=bar"`, `"JAVA_OPTS=-Xmx512m -Dfoo=bar", "PRICE=$5", "TEMPLATE=${HOME}/x"`, 1))
+
+	var b strings.Builder
+	if err := writeCompose(&b, []*ContainerInfo{info}); err != nil {
+		t.Fatal(err)
+	}
+	checkGolden(t, "compose.golden", b.String())
+}
+
+func TestYAMLString(t *testing.T) {
+	tests := map[string]string{
+		"nginx:1.25":  `"nginx:1.25"`,
+		"no":          `"no"`,
+		"53:53/udp":   `"53:53/udp"`,
+		`say "hi"`:    `"say \"hi\""`,
+		"PRICE=$5":    `"PRICE=$$5"`,
+		"${HOME}/x":   `"$${HOME}/x"`,
+		"already $$":  `"already $$$$"`,
+		"line\nbreak": `"line\nbreak"`,
+	}
+	for in, want := range tests {
+		if got := yamlString(in); got != want {
+			t.Errorf("yamlString(%q) = %s, want %s", in, got, want)
+		}
+	}
+}
+
+func TestComposeDependsOn(t *testing.T) {
+	out := resetState(t)
+	db := testContainer(t, `"Labels":{"drun.profile":"backend, data"}`, "")
+	db.Name, db.ID = "/db", "db0000000000"
+	config := testContainer(t, "", "")
+	config.Name, config.ID = "/config", "cf0000000000"
+	web := testContainer(t, "", `"Links":["/db:/web/database","/cache:/web/cache"],"VolumesFrom":["cf0000000000:ro","db"]`)
+	web.Name = "/web"
+
+	services := map[string]string{"db": "db", db.ID: "db", "config": "config", config.ID: "config", "web": "web", web.ID: "web"}
+	if got, want := composeDependencies(web, services), []string{"config", "db"}; !slices.Equal(got, want) {
+		t.Errorf("depends_on = %q, want %q", got, want)
+	}
+	if !strings.Contains(out.String(), "web depends on cache, which is not being exported") {
+		t.Errorf("no warning for the link to a container left out:\n%s", out)
+	}
+
+	var b strings.Builder
+	if err := writeCompose(&b, []*ContainerInfo{db, config, web}); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"  db:\n    image: \"busybox:1.36\"\n    container_name: \"db\"\n    profiles:\n      - \"backend\"\n      - \"data\"\n",
+		"    depends_on:\n      - \"config\"\n      - \"db\"\n",
+	} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("compose output has no %q:\n%s", want, b.String())
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+)
+
+// fileConfig is the optional drun config file. Command line flags win over
+// anything set here.
+type fileConfig struct {
+	// SkipEnv replaces the default list of env prefixes that are not
+	// carried over, matched against "NAME=value" (so "LANG=" is exactly
+	// LANG and "LC_" is every LC_* variable). An empty list keeps all.
+	SkipEnv []string `json:"skip_env"`
+	// ConfirmMessage is the default for --confirm-message.
+	ConfirmMessage string `json:"confirm_message"`
+}
+
+// defaultSkipEnv are env vars the runtime or image sets on every container
+// and that would only pin stale values if copied into the new one.
+var defaultSkipEnv = []string{"PATH=", "HOSTNAME=", "HOME=", "TERM="}
+
+// skipEnvPrefixes is consulted by shouldSkipEnv; the config file can
+// replace it.
+var skipEnvPrefixes = defaultSkipEnv
+
+// defaultConfigPaths are tried in order when --config is not given: the
+// current directory first, so a project can carry its own settings.
+func defaultConfigPaths() []string {
+	paths := []string{".drun.json"}
+	if home, err := os.UserHomeDir(); err == nil {
+		paths = append(paths, filepath.Join(home, ".drun.json"))
+	}
+	return paths
+}
+
+// loadConfig reads the config file at path, or the first default location
+// that exists when path is empty. No file at a default location is not an
+// error; a missing explicit --config is.
+func loadConfig(path string) (*fileConfig, error) {
+	candidates := []string{path}
+	if path == "" {
+		candidates = defaultConfigPaths()
+	}
+
+	for _, candidate := range candidates {
+		data, err := os.ReadFile(candidate)
+		if errors.Is(err, os.ErrNotExist) && path == "" {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		var cfg fileConfig
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&cfg); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %v", candidate, err)
+		}
+		return &cfg, nil
+	}
+	return &fileConfig{}, nil
+}
+
+// runConfig implements `drun config schema`, which prints the JSON schema
+// of the config file for editors and linters.
+func runConfig(args []string) int {
+	if len(args) != 1 || args[0] != "schema" {
+		fmt.Fprintln(os.Stderr, "Usage: drun config schema")
+		return 2
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(configSchema()); err != nil {
+		printError("Failed to write schema: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// configSchema describes fileConfig as a JSON schema. It is built from the
+// struct by reflection so it always lists exactly the keys loadConfig
+// accepts; unknown keys are rejected there, hence additionalProperties.
+func configSchema() map[string]any {
+	t := reflect.TypeOf(fileConfig{})
+	properties := make(map[string]any, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if name != "" && name != "-" {
+			properties[name] = schemaType(t.Field(i).Type)
+		}
+	}
+	return map[string]any{
+		"$schema":              "https://json-schema.org/draft/2020-12/schema",
+		"title":                "drun config file",
+		"type":                 "object",
+		"properties":           properties,
+		"additionalProperties": false,
+	}
+}
+
+// schemaType maps a Go type to the JSON schema of its encoding.
+func schemaType(t reflect.Type) map[string]any {
+	switch t.Kind() {
+	case reflect.Pointer:
+		return schemaType(t.Elem())
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": schemaType(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": schemaType(t.Elem())}
+	}
+	return map[string]any{}
+}
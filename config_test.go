@@ -0,0 +1,43 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestConfigSchema(t *testing.T) {
+	resetState(t)
+	out := captureStdout(t, func() {
+		if code := run([]string{"config", "schema"}); code != 0 {
+			t.Errorf("exit code = %d", code)
+		}
+	})
+
+	var schema struct {
+		Type                 string                    `json:"type"`
+		AdditionalProperties bool                      `json:"additionalProperties"`
+		Properties           map[string]map[string]any `json:"properties"`
+	}
+	if err := json.Unmarshal([]byte(out), &schema); err != nil {
+		t.Fatalf("schema is not JSON: %v\n%s", err, out)
+	}
+	if schema.Type != "object" || schema.AdditionalProperties {
+		t.Errorf("schema is %s with additionalProperties %v, want a closed object", schema.Type, schema.AdditionalProperties)
+	}
+
+	want := map[string]map[string]any{
+		"skip_env":        {"type": "array", "items": map[string]any{"type": "string"}},
+		"confirm_message": {"type": "string"},
+	}
+	if !reflect.DeepEqual(schema.Properties, want) {
+		t.Errorf("properties = %v, want %v", schema.Properties, want)
+	}
+}
+
+func TestConfigUsage(t *testing.T) {
+	resetState(t)
+	if code := run([]string{"config", "dump"}); code != 2 {
+		t.Errorf("exit code = %d, want 2 for an unknown config subcommand", code)
+	}
+}
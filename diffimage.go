@@ -0,0 +1,134 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// imageConfig is the part of an image's (or a container's) Config that
+// `drun diff-image` compares.
+type imageConfig struct {
+	Entrypoint   []string            `json:"Entrypoint"`
+	Cmd          []string            `json:"Cmd"`
+	Env          []string            `json:"Env"`
+	ExposedPorts map[string]struct{} `json:"ExposedPorts"`
+	Volumes      map[string]struct{} `json:"Volumes"`
+	WorkingDir   string              `json:"WorkingDir"`
+	User         string              `json:"User"`
+	Labels       map[string]string   `json:"Labels"`
+	Healthcheck  *Healthcheck        `json:"Healthcheck"`
+}
+
+func getImageConfig(imageID string) (*imageConfig, error) {
+	output, err := dockerCommand("image", "inspect", "--format", "{{json .Config}}", imageID).Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect image %s: %v", imageID, err)
+	}
+
+	var config imageConfig
+	if err := json.Unmarshal(output, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse image config for %s: %v", imageID, err)
+	}
+	return &config, nil
+}
+
+// runDiffImage implements `drun diff-image <container>`: it prints how the
+// container's effective config departs from its image's defaults, i.e. the
+// settings that were overridden at run time.
+func runDiffImage(args []string) int {
+	fs := flag.NewFlagSet("drun diff-image", flag.ExitOnError)
+	addRuntimeFlags(fs)
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "Usage: drun diff-image [-H URL] [--runtime BINARY] <container_name>")
+		return 2
+	}
+
+	info, err := getContainerInfo(fs.Arg(0))
+	if err != nil {
+		printError("Failed to get container info: %v\n", err)
+		return 1
+	}
+
+	var raw struct {
+		Config imageConfig `json:"Config"`
+	}
+	if err := json.Unmarshal(info.raw, &raw); err != nil {
+		printError("Failed to parse container config: %v\n", err)
+		return 1
+	}
+	image, err := getImageConfig(info.ImageID)
+	if err != nil {
+		printError("Failed to get image config: %v\n", err)
+		return 1
+	}
+
+	deltas := diffImageConfig(image, &raw.Config)
+	if len(deltas) == 0 {
+		printInfo("Container %s matches the defaults of %s\n", fs.Arg(0), info.Config.Image)
+		return 0
+	}
+	printInfo("Container %s overrides these defaults of %s:\n", fs.Arg(0), info.Config.Image)
+	for _, delta := range deltas {
+		fmt.Printf("  %s\n", delta)
+	}
+	return 0
+}
+
+func diffImageConfig(image, container *imageConfig) []string {
+	var deltas []string
+	if !reflect.DeepEqual(image.Entrypoint, container.Entrypoint) {
+		deltas = append(deltas, fmt.Sprintf("entrypoint: %s -> %s", formatList(image.Entrypoint), formatList(container.Entrypoint)))
+	}
+	if !reflect.DeepEqual(image.Cmd, container.Cmd) {
+		deltas = append(deltas, fmt.Sprintf("cmd: %s -> %s", formatList(image.Cmd), formatList(container.Cmd)))
+	}
+	if image.WorkingDir != container.WorkingDir {
+		deltas = append(deltas, fmt.Sprintf("workdir: %q -> %q", image.WorkingDir, container.WorkingDir))
+	}
+	if image.User != container.User {
+		deltas = append(deltas, fmt.Sprintf("user: %q -> %q", image.User, container.User))
+	}
+
+	imageEnv := make(map[string]bool, len(image.Env))
+	for _, env := range image.Env {
+		imageEnv[env] = true
+	}
+	for _, env := range container.Env {
+		if !imageEnv[env] {
+			deltas = append(deltas, "env: + "+env)
+		}
+	}
+
+	deltas = append(deltas, diffSet("port", image.ExposedPorts, container.ExposedPorts)...)
+	deltas = append(deltas, diffSet("volume", image.Volumes, container.Volumes)...)
+	return deltas
+}
+
+func diffSet(kind string, image, container map[string]struct{}) []string {
+	var deltas []string
+	for key := range container {
+		if _, ok := image[key]; !ok {
+			deltas = append(deltas, fmt.Sprintf("%s: + %s", kind, key))
+		}
+	}
+	for key := range image {
+		if _, ok := container[key]; !ok {
+			deltas = append(deltas, fmt.Sprintf("%s: - %s", kind, key))
+		}
+	}
+	sort.Strings(deltas)
+	return deltas
+}
+
+func formatList(values []string) string {
+	if values == nil {
+		return "(none)"
+	}
+	return "[" + strings.Join(values, " ") + "]"
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestDiffImageConfig(t *testing.T) {
+	image := imageConfig{
+		Entrypoint:   []string{"/docker-entrypoint.sh"},
+		Cmd:          []string{"nginx", "-g", "daemon off;"},
+		Env:          []string{"PATH=/usr/bin", "NGINX_VERSION=1.25.3"},
+		ExposedPorts: map[string]struct{}{"80/tcp": {}},
+		Volumes:      map[string]struct{}{"/var/cache/nginx": {}},
+	}
+	tests := []struct {
+		name   string
+		change func(c *imageConfig)
+		want   []string
+	}{
+		{"matches defaults", func(c *imageConfig) {}, nil},
+		{"entrypoint", func(c *imageConfig) { c.Entrypoint = []string{"/bin/sh", "-c"} },
+			[]string{"entrypoint: [/docker-entrypoint.sh] -> [/bin/sh -c]"}},
+		{"entrypoint cleared", func(c *imageConfig) { c.Entrypoint = nil },
+			[]string{"entrypoint: [/docker-entrypoint.sh] -> (none)"}},
+		{"cmd", func(c *imageConfig) { c.Cmd = []string{"nginx-debug"} },
+			[]string{"cmd: [nginx -g daemon off;] -> [nginx-debug]"}},
+		{"env", func(c *imageConfig) { c.Env = append(slices.Clone(c.Env), "APP_MODE=production") },
+			[]string{"env: + APP_MODE=production"}},
+		{"env overridden", func(c *imageConfig) { c.Env = []string{"PATH=/usr/bin", "NGINX_VERSION=1.27.0"} },
+			[]string{"env: + NGINX_VERSION=1.27.0"}},
+		{"port", func(c *imageConfig) { c.ExposedPorts = map[string]struct{}{"80/tcp": {}, "443/tcp": {}} },
+			[]string{"port: + 443/tcp"}},
+		{"port removed", func(c *imageConfig) { c.ExposedPorts = nil },
+			[]string{"port: - 80/tcp"}},
+		{"volume", func(c *imageConfig) { c.Volumes = map[string]struct{}{"/data": {}} },
+			[]string{"volume: + /data", "volume: - /var/cache/nginx"}},
+		{"workdir and user", func(c *imageConfig) { c.WorkingDir, c.User = "/srv", "nginx" },
+			[]string{`workdir: "" -> "/srv"`, `user: "" -> "nginx"`}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			container := image
+			tt.change(&container)
+			if got := diffImageConfig(&image, &container); !slices.Equal(got, tt.want) {
+				t.Errorf("diffImageConfig = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunDiffImage(t *testing.T) {
+	const imageJSON = `{"Cmd":["nginx","-g","daemon off;"],"Env":["PATH=/usr/bin"],"ExposedPorts":{"80/tcp":{}}}`
+	tests := []struct {
+		name      string
+		config    string
+		want      string
+		wantDelta []string
+	}{
+		{"matches defaults", imageJSON, "Container web matches the defaults of nginx:1.25", nil},
+		{"overrides", `{"Cmd":["nginx-debug"],"Env":["PATH=/usr/bin","APP_MODE=production"],"ExposedPorts":{"80/tcp":{}}}`,
+			"Container web overrides these defaults of nginx:1.25:",
+			[]string{"  cmd: [nginx -g daemon off;] -> [nginx-debug]", "  env: + APP_MODE=production"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			messages := resetState(t)
+			f := newFakeDocker(t)
+			config := strings.Replace(tt.config, "{", `{"Image":"nginx:1.25",`, 1)
+			f.answer(`[{"Id":"web1","Name":"/web","Image":"sha256:web","Config":`+config+`,"HostConfig":{}}]`+"\n", "docker", "inspect", "web")
+			f.answer(imageJSON+"\n", "docker", "image", "inspect", "--format", "{{json .Config}}", "sha256:web")
+
+			var code int
+			out := captureStdout(t, func() { code = run([]string{"diff-image", "web"}) })
+			if code != 0 {
+				t.Fatalf("exit code = %d", code)
+			}
+			if !strings.Contains(messages.String(), tt.want) {
+				t.Errorf("messages %q, want %q", messages, tt.want)
+			}
+			var deltas []string
+			if out != "" {
+				deltas = strings.Split(strings.TrimSuffix(out, "\n"), "\n")
+			}
+			if !slices.Equal(deltas, tt.wantDelta) {
+				t.Errorf("deltas = %q, want %q", deltas, tt.wantDelta)
+			}
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"strings"
+)
+
+// envFileEntries is what --env-file covers: KEY=VALUE lines by their full
+// text, and bare KEY lines (which docker fills in from the caller's
+// environment) by name, whatever value the container got.
+type envFileEntries struct {
+	values map[string]bool
+	names  map[string]bool
+}
+
+// readEnvFile parses a file in the format of docker run --env-file: one
+// variable per line, with blank lines and # comments ignored.
+func readEnvFile(path string) (*envFileEntries, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	entries := &envFileEntries{values: make(map[string]bool), names: make(map[string]bool)}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimLeft(scanner.Text(), " \t")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.Contains(line, "=") {
+			entries.values[line] = true
+		} else {
+			entries.names[strings.TrimSpace(line)] = true
+		}
+	}
+	return entries, scanner.Err()
+}
+
+// covers reports whether an env entry of the container comes from the file,
+// so it is left to --env-file instead of being inlined as -e.
+func (e *envFileEntries) covers(env string) bool {
+	if e == nil {
+		return false
+	}
+	name, _, _ := strings.Cut(env, "=")
+	return e.values[env] || e.names[name]
+}
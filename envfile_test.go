@@ -0,0 +1,37 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestEnvFile(t *testing.T) {
+	resetState(t)
+	path := filepath.Join(t.TempDir(), "prod.env")
+	file := "# database\nDB_HOST=db.internal\n  DB_PASSWORD=hunter2\n\nAPI_TOKEN\nLOG_LEVEL=info\n"
+	if err := os.WriteFile(path, []byte(file), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, code := parseFlags([]string{"--env-file", path, "app"}); code != 0 {
+		t.Fatalf("parseFlags exit code = %d", code)
+	}
+	info := testContainer(t, `"Env":["DB_HOST=db.internal","DB_PASSWORD=hunter2","API_TOKEN=from-the-shell","LOG_LEVEL=debug","APP_MODE=production"]`, "")
+
+	argv := buildRunArgs(info)
+	if got := flagValues(argv, "--env-file"); !slices.Equal(got, []string{path}) {
+		t.Errorf("--env-file %q, want %s", got, path)
+	}
+	// LOG_LEVEL differs from the file, so it was set with -e and stays inline.
+	if got, want := flagValues(argv, "-e"), []string{"LOG_LEVEL=debug", "APP_MODE=production"}; !slices.Equal(got, want) {
+		t.Errorf("-e %q, want %q", got, want)
+	}
+}
+
+func TestEnvFileMissing(t *testing.T) {
+	resetState(t)
+	if _, code := parseFlags([]string{"--env-file", filepath.Join(t.TempDir(), "missing.env"), "app"}); code == 0 {
+		t.Error("parseFlags accepted an --env-file that does not exist")
+	}
+}
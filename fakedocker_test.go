@@ -0,0 +1,429 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeDirEnv points the fake runtime at its rules and call log. The test
+// binary acts as the fake when it is run through a symlink named after one
+// of fakeBinaries, which newFakeDocker puts first on PATH.
+const fakeDirEnv = "DRUN_FAKE_DIR"
+
+var fakeBinaries = []string{"docker", "podman", "cosign"}
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+func TestMain(m *testing.M) {
+	if dir := os.Getenv(fakeDirEnv); dir != "" && slices.Contains(fakeBinaries, filepath.Base(os.Args[0])) {
+		os.Exit(runFake(dir, append([]string{filepath.Base(os.Args[0])}, os.Args[1:]...)))
+	}
+	os.Exit(m.Run())
+}
+
+// fakeRule is one scripted answer of the fake runtime. Args is matched
+// against the start of the argv, with "*" matching any single argument.
+// Rules added later win, so a test can override the defaults; a rule with
+// Times answers only that many calls before falling through.
+type fakeRule struct {
+	ID     int           `json:"id"`
+	Args   []string      `json:"args"`
+	Stdout string        `json:"stdout,omitempty"`
+	Stderr string        `json:"stderr,omitempty"`
+	Exit   int           `json:"exit,omitempty"`
+	Times  int           `json:"times,omitempty"`
+	Sleep  time.Duration `json:"sleep,omitempty"`
+}
+
+func (r fakeRule) matches(argv []string) bool {
+	if len(r.Args) > len(argv) {
+		return false
+	}
+	for i, arg := range r.Args {
+		if arg != "*" && arg != argv[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// fakeCall is a line of the call log: one when a call starts, with its
+// argv, and one with the same PID when it returns.
+type fakeCall struct {
+	PID   int      `json:"pid"`
+	Argv  []string `json:"argv,omitempty"`
+	Start int64    `json:"start,omitempty"`
+	End   int64    `json:"end,omitempty"`
+}
+
+func runFake(dir string, argv []string) int {
+	logCall(dir, fakeCall{PID: os.Getpid(), Argv: argv, Start: time.Now().UnixNano()})
+	defer func() { logCall(dir, fakeCall{PID: os.Getpid(), End: time.Now().UnixNano()}) }()
+
+	data, err := os.ReadFile(filepath.Join(dir, "rules.json"))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "fake %s: %v\n", argv[0], err)
+		return 1
+	}
+	var rules []fakeRule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		fmt.Fprintf(os.Stderr, "fake %s: %v\n", argv[0], err)
+		return 1
+	}
+
+	for _, rule := range slices.Backward(rules) {
+		if !rule.matches(argv) || !claim(dir, rule) {
+			continue
+		}
+		time.Sleep(rule.Sleep)
+		os.Stdout.WriteString(rule.Stdout)
+		os.Stderr.WriteString(rule.Stderr)
+		return rule.Exit
+	}
+	fmt.Fprintf(os.Stderr, "fake %s: no rule for %q\n", argv[0], argv[1:])
+	return 1
+}
+
+// claim takes one of the answers of a rule limited by Times. Marker files
+// created exclusively keep concurrent calls from sharing an answer.
+func claim(dir string, rule fakeRule) bool {
+	if rule.Times == 0 {
+		return true
+	}
+	for n := 0; n < rule.Times; n++ {
+		f, err := os.OpenFile(filepath.Join(dir, fmt.Sprintf("claim-%d-%d", rule.ID, n)), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+		if err == nil {
+			f.Close()
+			return true
+		}
+	}
+	return false
+}
+
+func logCall(dir string, call fakeCall) {
+	line, _ := json.Marshal(call)
+	f, err := os.OpenFile(filepath.Join(dir, "calls.log"), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	f.Write(append(line, '\n'))
+}
+
+// fakeDocker scripts the runtime binaries for one test.
+type fakeDocker struct {
+	t     *testing.T
+	dir   string
+	rules []fakeRule
+}
+
+// fakeDefaults answer the image and daemon queries most flows make: a
+// native linux/amd64 image with an empty config, pulled as sha256:new.
+var fakeDefaults = []fakeRule{
+	{Args: []string{"docker", "version", "--format"}, Stdout: "linux/amd64\n"},
+	{Args: []string{"docker", "image", "inspect", "--format", "{{.Os}}/{{.Architecture}}{{if .Variant}}/{{.Variant}}{{end}}"}, Stdout: "linux/amd64\n"},
+	{Args: []string{"docker", "image", "inspect", "--format", "{{json .Config}}"}, Stdout: "{}\n"},
+	{Args: []string{"docker", "image", "inspect", "--format", "{{.Id}}"}, Stdout: "sha256:new\n"},
+}
+
+func newFakeDocker(t *testing.T) *fakeDocker {
+	t.Helper()
+	dir := t.TempDir()
+	bin := filepath.Join(dir, "bin")
+	if err := os.Mkdir(bin, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range fakeBinaries {
+		if err := os.Symlink(exe, filepath.Join(bin, name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv(fakeDirEnv, dir)
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	f := &fakeDocker{t: t, dir: dir}
+	for _, rule := range fakeDefaults {
+		f.on(rule)
+	}
+	return f
+}
+
+// on adds a rule, which takes precedence over all earlier ones.
+func (f *fakeDocker) on(rule fakeRule) {
+	f.t.Helper()
+	rule.ID = len(f.rules)
+	f.rules = append(f.rules, rule)
+	data, err := json.Marshal(f.rules)
+	if err != nil {
+		f.t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(f.dir, "rules.json"), data, 0o600); err != nil {
+		f.t.Fatal(err)
+	}
+}
+
+func (f *fakeDocker) answer(stdout string, args ...string) {
+	f.t.Helper()
+	f.on(fakeRule{Args: args, Stdout: stdout})
+}
+
+func (f *fakeDocker) fail(stderr string, args ...string) {
+	f.t.Helper()
+	f.on(fakeRule{Args: args, Stderr: stderr, Exit: 1})
+}
+
+// inspect makes docker inspect return the given inspect object for name.
+func (f *fakeDocker) inspect(name, container string) {
+	f.t.Helper()
+	f.answer("["+container+"]\n", "docker", "inspect", name)
+}
+
+// allowChanges makes every command that changes containers succeed, on top
+// of which a test scripts the failures it is about.
+func (f *fakeDocker) allowChanges() {
+	f.t.Helper()
+	for _, command := range []string{"pull", "stop", "kill", "rename", "run", "rm", "start", "commit", "update", "exec"} {
+		f.answer("", "docker", command)
+	}
+	f.answer("", "docker", "network", "connect")
+	f.answer("", "docker", "network", "create")
+}
+
+func (f *fakeDocker) log() []fakeCall {
+	f.t.Helper()
+	data, err := os.ReadFile(filepath.Join(f.dir, "calls.log"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		f.t.Fatal(err)
+	}
+	var calls []fakeCall
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		var call fakeCall
+		if err := json.Unmarshal(scanner.Bytes(), &call); err != nil {
+			f.t.Fatal(err)
+		}
+		calls = append(calls, call)
+	}
+	return calls
+}
+
+// calls returns the argv of every call, in the order they started.
+func (f *fakeDocker) calls() [][]string {
+	var calls [][]string
+	for _, call := range f.log() {
+		if call.Argv != nil {
+			calls = append(calls, call.Argv)
+		}
+	}
+	return calls
+}
+
+// index returns the position of the first call matching args, or -1.
+func (f *fakeDocker) index(args ...string) int {
+	for i, argv := range f.calls() {
+		if (fakeRule{Args: args}).matches(argv) {
+			return i
+		}
+	}
+	return -1
+}
+
+// count returns how many calls match args.
+func (f *fakeDocker) count(args ...string) int {
+	n := 0
+	for _, argv := range f.calls() {
+		if (fakeRule{Args: args}).matches(argv) {
+			n++
+		}
+	}
+	return n
+}
+
+// call returns the first call matching args, failing the test without one.
+func (f *fakeDocker) call(args ...string) []string {
+	f.t.Helper()
+	i := f.index(args...)
+	if i < 0 {
+		f.t.Fatalf("no call matching %q; calls:\n%s", args, f.dump())
+	}
+	return f.calls()[i]
+}
+
+func (f *fakeDocker) dump() string {
+	var b strings.Builder
+	for _, argv := range f.calls() {
+		fmt.Fprintf(&b, "  %s\n", strings.Join(argv, " "))
+	}
+	return b.String()
+}
+
+// maxConcurrent returns the largest number of calls matching args that were
+// running at the same time.
+func (f *fakeDocker) maxConcurrent(args ...string) int {
+	type event struct {
+		at    int64
+		delta int
+	}
+	var events []event
+	started := make(map[int]bool)
+	for _, call := range f.log() {
+		switch {
+		case call.Argv != nil && (fakeRule{Args: args}).matches(call.Argv):
+			started[call.PID] = true
+			events = append(events, event{call.Start, 1})
+		case call.End != 0 && started[call.PID]:
+			events = append(events, event{call.End, -1})
+		}
+	}
+	slices.SortStableFunc(events, func(a, b event) int {
+		if a.at != b.at {
+			return int(a.at - b.at)
+		}
+		return a.delta - b.delta
+	})
+	running, peak := 0, 0
+	for _, e := range events {
+		running += e.delta
+		peak = max(peak, running)
+	}
+	return peak
+}
+
+// resetState gives a test the defaults of a fresh drun invocation, with HOME
+// and the state file in a temporary directory, and returns the buffer that
+// messages are written to. Everything is restored when the test ends.
+func resetState(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	savedOpts, savedMessages, savedOutput := opts, messages, commandOutput
+	savedCache, savedSkipEnv, savedPlatform := inspectCache, skipEnvPrefixes, daemonPlatformCache
+	savedReader, savedInput := stdinReader, promptInput
+	t.Cleanup(func() {
+		opts, messages, commandOutput = savedOpts, savedMessages, savedOutput
+		inspectCache, skipEnvPrefixes, daemonPlatformCache = savedCache, savedSkipEnv, savedPlatform
+		stdinReader, promptInput = savedReader, savedInput
+	})
+
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv(runtimeEnv, "")
+	t.Setenv("DOCKER_HOST", "")
+
+	opts = options{}
+	newFlagSet()
+	opts.stateFile = filepath.Join(home, "state.json")
+
+	var buf bytes.Buffer
+	messages, commandOutput = &buf, io.Discard
+	inspectCache = make(map[string]json.RawMessage)
+	skipEnvPrefixes = defaultSkipEnv
+	daemonPlatformCache = ""
+	stdinReader = bufio.NewReader(strings.NewReader(""))
+	return &buf
+}
+
+// setupFlow prepares a recreate against the fake runtime: --yes, no wait
+// for the new container, and every change succeeding unless scripted
+// otherwise.
+func setupFlow(t *testing.T) (*fakeDocker, *bytes.Buffer) {
+	t.Helper()
+	out := resetState(t)
+	f := newFakeDocker(t)
+	f.allowChanges()
+	opts.yes = true
+	opts.healthTimeout = 0
+	return f, out
+}
+
+// processOne runs the recreate of a single container and returns its
+// result the way runUpdate records it.
+func processOne(name string) containerResult {
+	result := containerResult{Container: name}
+	if err := processContainer(&result); err != nil {
+		result.Status = statusFailed
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// fixture returns testdata/<name>.json.
+func fixture(t *testing.T, name string) string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name+".json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(bytes.TrimSpace(data))
+}
+
+// parseInfo decodes an inspect object for tests that build the argv without
+// a runtime: the image defaults and platform are preset so nothing is
+// queried.
+func parseInfo(t *testing.T, raw string) *ContainerInfo {
+	t.Helper()
+	info, err := parseContainerInfo(json.RawMessage(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	info.image = &imageConfig{}
+	return info
+}
+
+// captureStdout returns what fn writes to os.Stdout.
+func captureStdout(t *testing.T, fn func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved := os.Stdout
+	os.Stdout = w
+	done := make(chan []byte)
+	go func() {
+		data, _ := io.ReadAll(r)
+		done <- data
+	}()
+	defer func() {
+		os.Stdout = saved
+	}()
+	fn()
+	w.Close()
+	return string(<-done)
+}
+
+// checkGolden compares got with testdata/<name>, rewriting the file instead
+// when the tests run with -update.
+func checkGolden(t *testing.T, name, got string) {
+	t.Helper()
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := os.WriteFile(path, []byte(got), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != string(want) {
+		t.Errorf("output differs from %s (rerun with -update to accept):\ngot:\n%s\nwant:\n%s", path, got, want)
+	}
+}
@@ -0,0 +1,814 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRecreateManyFlags(t *testing.T) {
+	f, _ := setupFlow(t)
+	f.inspect("web", fixture(t, "many-flags"))
+
+	result := processOne("web")
+	if result.Status != statusRestarted {
+		t.Fatalf("status = %s (%s), want %s", result.Status, result.Error, statusRestarted)
+	}
+	run := f.call("docker", "run")
+	if !slices.Equal(run, manyFlagsArgv) {
+		t.Errorf("docker run:\ngot  %q\nwant %q", run, manyFlagsArgv)
+	}
+	checkRunSyntax(t, run)
+}
+
+func TestDryRunJSONPlan(t *testing.T) {
+	f, _ := setupFlow(t)
+	f.inspect("web", fixture(t, "many-flags"))
+	opts.dryRun, opts.json = true, true
+
+	var result containerResult
+	out := captureStdout(t, func() { result = processOne("web") })
+	if result.Status != statusPlanned {
+		t.Fatalf("status = %s (%s), want %s", result.Status, result.Error, statusPlanned)
+	}
+
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(out), &doc); err != nil {
+		t.Fatalf("plan is not a JSON object: %v\n%s", err, out)
+	}
+	for _, key := range []string{"container", "image", "actions"} {
+		if _, ok := doc[key]; !ok {
+			t.Errorf("plan has no %q: %s", key, out)
+		}
+	}
+
+	var p plan
+	if err := json.Unmarshal([]byte(out), &p); err != nil {
+		t.Fatal(err)
+	}
+	if p.Container != "web" || p.Image != "nginx:1.25" {
+		t.Errorf("plan is for %s (%s), want web (nginx:1.25)", p.Container, p.Image)
+	}
+	var actions []string
+	for _, action := range p.Actions {
+		actions = append(actions, action.Action)
+	}
+	if want := []string{"pull", "stop", "rename", "run", "rm"}; !slices.Equal(actions, want) {
+		t.Errorf("actions = %q, want %q", actions, want)
+	}
+	if run := p.Actions[3]; !slices.Equal(run.Argv, manyFlagsArgv) {
+		t.Errorf("run argv = %q, want %q", run.Argv, manyFlagsArgv)
+	}
+	var rollback []string
+	for _, action := range p.Rollback {
+		rollback = append(rollback, action.String())
+	}
+	if want := []string{"rm -f web", "rename web_drun_backup -> web", "start web"}; !slices.Equal(rollback, want) {
+		t.Errorf("rollback = %q, want %q", rollback, want)
+	}
+
+	for _, command := range []string{"pull", "stop", "rename", "run", "rm"} {
+		if f.count("docker", command) > 0 {
+			t.Errorf("dry run called docker %s", command)
+		}
+	}
+}
+
+func TestKeepNameOnConflict(t *testing.T) {
+	const conflict = `docker: Error response from daemon: Conflict. The container name "/app" is already in use by container "abc123".`
+	argv := []string{"docker", "run", "-d", "--name", "app", "busybox:1.36"}
+
+	t.Run("retries until the name is released", func(t *testing.T) {
+		f, _ := setupFlow(t)
+		f.on(fakeRule{Args: []string{"docker", "run"}, Stderr: conflict, Exit: 1, Times: 1})
+		opts.keepNameRetry, opts.nameReleaseWindow = true, 5*time.Second
+
+		if err := executeRunCommand(argv); err != nil {
+			t.Fatal(err)
+		}
+		if n := f.count("docker", "run"); n != 2 {
+			t.Errorf("docker run called %d times, want 2", n)
+		}
+	})
+
+	t.Run("gives up after the window", func(t *testing.T) {
+		f, _ := setupFlow(t)
+		f.fail(conflict, "docker", "run")
+		opts.keepNameRetry, opts.nameReleaseWindow = true, 0
+
+		err := executeRunCommand(argv)
+		if err == nil || !strings.Contains(err.Error(), "still in use") {
+			t.Fatalf("err = %v, want the name to be reported as still in use", err)
+		}
+		if n := f.count("docker", "run"); n != 1 {
+			t.Errorf("docker run called %d times, want 1", n)
+		}
+	})
+
+	t.Run("does not retry without the flag", func(t *testing.T) {
+		f, _ := setupFlow(t)
+		f.on(fakeRule{Args: []string{"docker", "run"}, Stderr: conflict, Exit: 1, Times: 1})
+
+		if err := executeRunCommand(argv); err == nil {
+			t.Fatal("docker run succeeded, want the conflict")
+		}
+		if n := f.count("docker", "run"); n != 1 {
+			t.Errorf("docker run called %d times, want 1", n)
+		}
+	})
+}
+
+// startedAt returns the many-flags fixture with the container started at t.
+func startedAt(t *testing.T, at time.Time) string {
+	return strings.Replace(fixture(t, "many-flags"), `"2026-10-01T10:00:00Z"`, `"`+at.UTC().Format(time.RFC3339Nano)+`"`, 1)
+}
+
+func TestMinUptime(t *testing.T) {
+	t.Run("skips a recently started container", func(t *testing.T) {
+		f, out := setupFlow(t)
+		f.inspect("web", startedAt(t, time.Now().Add(-30*time.Second)))
+		opts.minUptime = 10 * time.Minute
+
+		if result := processOne("web"); result.Status != statusSkipped {
+			t.Fatalf("status = %s (%s), want %s", result.Status, result.Error, statusSkipped)
+		}
+		if !strings.Contains(out.String(), "less than --min-uptime") {
+			t.Errorf("no skip message:\n%s", out)
+		}
+		for _, command := range []string{"pull", "stop", "run"} {
+			if f.count("docker", command) > 0 {
+				t.Errorf("skipped container got docker %s", command)
+			}
+		}
+	})
+
+	t.Run("recreates a container up for long enough", func(t *testing.T) {
+		f, _ := setupFlow(t)
+		f.inspect("web", startedAt(t, time.Now().Add(-time.Hour)))
+		opts.minUptime = 10 * time.Minute
+
+		if result := processOne("web"); result.Status != statusRestarted {
+			t.Fatalf("status = %s (%s), want %s", result.Status, result.Error, statusRestarted)
+		}
+		f.call("docker", "run")
+	})
+}
+
+func TestTrackDigestRotation(t *testing.T) {
+	f, _ := setupFlow(t)
+	f.inspect("web", fixture(t, "many-flags"))
+	f.answer(`["nginx@sha256:d1"]`, "docker", "image", "inspect", "--format", "{{json .RepoDigests}}", "sha256:old")
+	opts.trackDigest = true
+	resolves := func(digest string) {
+		f.answer(`{"digest":"`+digest+`"}`, "docker", "buildx", "imagetools", "inspect", "--format", "{{json .Manifest}}", "nginx:1.25")
+		inspectCache = make(map[string]json.RawMessage)
+	}
+
+	runs := []struct {
+		digest string
+		want   string
+	}{
+		{"sha256:d1", statusSkipped},   // the running image is the one the tag points to
+		{"sha256:d2", statusRestarted}, // the tag moved
+		{"sha256:d2", statusSkipped},   // the state file has d2 now
+		{"sha256:d3", statusRestarted}, // and moved again
+	}
+	for i, run := range runs {
+		resolves(run.digest)
+		before := f.count("docker", "run")
+		result := processOne("web")
+		if result.Status != run.want {
+			t.Fatalf("run %d (%s): status = %s (%s), want %s", i+1, run.digest, result.Status, result.Error, run.want)
+		}
+		recreated := f.count("docker", "run") > before
+		if recreated != (run.want == statusRestarted) {
+			t.Errorf("run %d (%s): recreated = %v", i+1, run.digest, recreated)
+		}
+	}
+
+	state, err := loadState(opts.stateFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := state["web"].Digest; got != "sha256:d3" {
+		t.Errorf("recorded digest = %q, want sha256:d3", got)
+	}
+}
+
+func TestStepDeadlines(t *testing.T) {
+	const deadline = 200 * time.Millisecond
+	tests := []struct {
+		step    string
+		set     func()
+		want    string
+		stopped bool
+	}{
+		{"pull", func() { opts.pullTimeout = deadline }, "docker pull timed out after 200ms", false},
+		{"stop", func() { opts.stopDeadline = deadline }, "docker stop timed out after 200ms", false},
+		{"run", func() { opts.runTimeout = deadline }, "docker run timed out after 200ms", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.step, func(t *testing.T) {
+			f, _ := setupFlow(t)
+			f.inspect("web", fixture(t, "many-flags"))
+			f.on(fakeRule{Args: []string{"docker", tt.step}, Sleep: 10 * time.Second})
+			tt.set()
+
+			start := time.Now()
+			result := processOne("web")
+			if elapsed := time.Since(start); elapsed > 5*time.Second {
+				t.Errorf("took %s, the deadline did not abort the step", elapsed)
+			}
+			if result.Status != statusFailed || !strings.Contains(result.Error, tt.want) {
+				t.Fatalf("result = %s %q, want a failure with %q", result.Status, result.Error, tt.want)
+			}
+			if stopped := f.count("docker", "stop") > 0; stopped != (tt.stopped || tt.step == "stop") {
+				t.Errorf("docker stop called = %v", stopped)
+			}
+			if tt.step != "run" && f.count("docker", "run") > 0 {
+				t.Errorf("docker run called after the %s timed out", tt.step)
+			}
+		})
+	}
+}
+
+func TestRestartCountNote(t *testing.T) {
+	f, _ := setupFlow(t)
+	f.inspect("web", strings.Replace(fixture(t, "many-flags"), `"RestartCount": 0`, `"RestartCount": 2`, 1))
+
+	result := processOne("web")
+	if result.Status != statusRestarted {
+		t.Fatalf("status = %s (%s), want %s", result.Status, result.Error, statusRestarted)
+	}
+	if len(result.Notes) != 1 || !strings.Contains(result.Notes[0], "2") || !strings.Contains(result.Notes[0], "on-failure:3") {
+		t.Errorf("notes = %q, want one about the 2 reset restarts of on-failure:3", result.Notes)
+	}
+	if got := flagValues(f.call("docker", "run"), "--restart"); !slices.Equal(got, []string{"on-failure:3"}) {
+		t.Errorf("--restart %q, want on-failure:3", got)
+	}
+
+	f, _ = setupFlow(t)
+	f.inspect("web", fixture(t, "many-flags"))
+	if result := processOne("web"); len(result.Notes) != 0 {
+		t.Errorf("notes = %q for a container that never restarted", result.Notes)
+	}
+}
+
+func TestRecreateNoPull(t *testing.T) {
+	f, _ := setupFlow(t)
+	unchanged := strings.Replace(fixture(t, "many-flags"), `"sha256:old"`, `"sha256:new"`, 1)
+	f.inspect("web", unchanged)
+
+	state := filepath.Join(t.TempDir(), "state.json")
+	if code := run([]string{"recreate", "--no-pull", "-y", "--health-timeout", "0", "--state-file", state, "web"}); code != 0 {
+		t.Fatalf("exit code = %d; calls:\n%s", code, f.dump())
+	}
+	if n := f.count("docker", "pull"); n != 0 {
+		t.Errorf("docker pull called %d times under recreate --no-pull", n)
+	}
+	// The image is unchanged, which only skips a plain update.
+	if f.index("docker", "stop") > f.index("docker", "run") || f.index("docker", "run") < 0 {
+		t.Errorf("want the container stopped and run again; calls:\n%s", f.dump())
+	}
+}
+
+func TestUnchangedImage(t *testing.T) {
+	tests := []struct {
+		name string
+		tag  string
+		want string
+	}{
+		{"same reference", "", statusSkipped},
+		// nginx:stable points at the same image as nginx:1.25, but the
+		// container still has to be moved to the new tag.
+		{"--tag to an identical image", "stable", statusRestarted},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, _ := setupFlow(t)
+			f.inspect("web", strings.Replace(fixture(t, "many-flags"), `"sha256:old"`, `"sha256:new"`, 1))
+			opts.tag = tt.tag
+
+			if result := processOne("web"); result.Status != tt.want {
+				t.Fatalf("status = %s (%s), want %s", result.Status, result.Error, tt.want)
+			}
+			if tt.want == statusSkipped {
+				if n := f.count("docker", "run"); n != 0 {
+					t.Errorf("docker run called %d times for an unchanged image", n)
+				}
+				return
+			}
+			if run := f.call("docker", "run"); !slices.Contains(run, "nginx:stable") {
+				t.Errorf("run = %q, want it to use nginx:stable", run)
+			}
+		})
+	}
+}
+
+func TestMinFreeSpaceAborts(t *testing.T) {
+	f, _ := setupFlow(t)
+	f.inspect("web", fixture(t, "many-flags"))
+	f.answer(t.TempDir()+"\n", "docker", "info", "--format", "{{.DockerRootDir}}")
+	opts.minFreeSpace = 1 << 60 // more than any test machine has free
+
+	result := processOne("web")
+	if result.Status != statusFailed || !strings.Contains(result.Error, "below --min-free-space") {
+		t.Fatalf("result = %s %q, want the free space check to fail", result.Status, result.Error)
+	}
+	for _, command := range []string{"pull", "stop", "run"} {
+		if f.count("docker", command) > 0 {
+			t.Errorf("docker %s called after the free space check failed", command)
+		}
+	}
+}
+
+func TestCidfileRecreate(t *testing.T) {
+	f, _ := setupFlow(t)
+	cidfile := filepath.Join(t.TempDir(), "web.cid")
+	if err := os.WriteFile(cidfile, []byte("4f1c2b3a5d6e\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	f.inspect("web", strings.Replace(fixture(t, "many-flags"), `"HostConfig": {`, `"HostConfig": {"ContainerIDFile": "`+cidfile+`",`, 1))
+
+	if result := processOne("web"); result.Status != statusRestarted {
+		t.Fatalf("status = %s (%s), want %s", result.Status, result.Error, statusRestarted)
+	}
+	if got := flagValues(f.call("docker", "run"), "--cidfile"); !slices.Equal(got, []string{cidfile}) {
+		t.Errorf("--cidfile %q, want %s", got, cidfile)
+	}
+	if _, err := os.Stat(cidfile); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("stale cidfile left in place: %v", err)
+	}
+}
+
+func TestCidfileRestoredOnRollback(t *testing.T) {
+	f, _ := setupFlow(t)
+	cidfile := filepath.Join(t.TempDir(), "web.cid")
+	raw := strings.Replace(fixture(t, "many-flags"), `"HostConfig": {`, `"HostConfig": {"ContainerIDFile": "`+cidfile+`",`, 1)
+	if err := os.WriteFile(cidfile, []byte("4f1c2b3a5d6e"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	f.inspect("web", raw)
+	f.fail("boom", "docker", "run")
+	f.answer("", "docker", "container", "inspect", "web")
+
+	if result := processOne("web"); result.Status != statusFailed {
+		t.Fatalf("status = %s (%s), want %s", result.Status, result.Error, statusFailed)
+	}
+	f.call("docker", "rename", "web_drun_backup", "web")
+	data, err := os.ReadFile(cidfile)
+	if err != nil {
+		t.Fatalf("cidfile not written back for the restored container: %v", err)
+	}
+	if id := parseInfo(t, raw).ID; string(data) != id {
+		t.Errorf("cidfile = %q, want the restored container's ID %s", data, id)
+	}
+}
+
+func TestCidfileRemoteDaemon(t *testing.T) {
+	f, _ := setupFlow(t)
+	cidfile := filepath.Join(t.TempDir(), "web.cid")
+	if err := os.WriteFile(cidfile, []byte("unrelated local file\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	opts.host = "ssh://deploy@host1"
+	raw := strings.Replace(fixture(t, "many-flags"), `"HostConfig": {`, `"HostConfig": {"ContainerIDFile": "`+cidfile+`",`, 1)
+	f.answer("["+raw+"]\n", "docker", "--host", "ssh://deploy@host1", "inspect", "web")
+
+	result := processOne("web")
+	if result.Status != statusFailed || !strings.Contains(result.Error, "ssh://deploy@host1") {
+		t.Fatalf("status = %s (%s), want a failure naming the remote daemon", result.Status, result.Error)
+	}
+	if data, err := os.ReadFile(cidfile); err != nil || string(data) != "unrelated local file\n" {
+		t.Errorf("local %s touched for a remote daemon: %q, %v", cidfile, data, err)
+	}
+	for _, step := range []string{"pull", "stop", "rename", "run"} {
+		if f.index("docker", "--host", "ssh://deploy@host1", step) >= 0 {
+			t.Errorf("docker %s ran before the cidfile check failed; calls:\n%s", step, f.dump())
+		}
+	}
+}
+
+func TestMaskSecrets(t *testing.T) {
+	f, out := setupFlow(t)
+	f.inspect("web", strings.Replace(fixture(t, "many-flags"), `"APP_MODE=production"`, `"APP_MODE=production", "DB_PASSWORD=hunter2", "API_TOKEN=t0k3n"`, 1))
+	opts.maskSecrets = true
+
+	if result := processOne("web"); result.Status != statusRestarted {
+		t.Fatalf("status = %s (%s), want %s", result.Status, result.Error, statusRestarted)
+	}
+	printed := out.String()
+	for _, want := range []string{"DB_PASSWORD=***", "API_TOKEN=***", "APP_MODE=production"} {
+		if !strings.Contains(printed, want) {
+			t.Errorf("printed command has no %s:\n%s", want, printed)
+		}
+	}
+	if strings.Contains(printed, "hunter2") || strings.Contains(printed, "t0k3n") {
+		t.Errorf("printed command shows a secret:\n%s", printed)
+	}
+	env := flagValues(f.call("docker", "run"), "-e")
+	for _, want := range []string{"DB_PASSWORD=hunter2", "API_TOKEN=t0k3n"} {
+		if !slices.Contains(env, want) {
+			t.Errorf("executed -e %q, want %s", env, want)
+		}
+	}
+}
+
+func TestMaskSecretsInChangesAndPlan(t *testing.T) {
+	tests := []struct {
+		name string
+		set  func()
+	}{
+		{"update", func() { opts.showChanges = true }},
+		{"dry run", func() { opts.dryRun = true }},
+		{"dry run json", func() { opts.dryRun, opts.json = true, true }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, out := setupFlow(t)
+			f.inspect("web", strings.Replace(fixture(t, "many-flags"), `"APP_MODE=production"`, `"APP_MODE=production", "DB_PASSWORD=hunter2", "API_TOKEN=t0k3n"`, 1))
+			previous := []string{"docker", "run", "-d", "--name", "web", "-e", "DB_PASSWORD=hunter2", "-e", "OLD_KEY=0ldk3y", "nginx:1.25"}
+			if err := recordState(opts.stateFile, "web", containerState{Image: "nginx:1.25", Argv: previous}); err != nil {
+				t.Fatal(err)
+			}
+			opts.maskSecrets = true
+			tt.set()
+
+			var result containerResult
+			stdout := captureStdout(t, func() { result = processOne("web") })
+			if result.Status == statusFailed {
+				t.Fatalf("failed: %s", result.Error)
+			}
+			printed := out.String() + stdout + strings.Join(result.Argv, " ")
+			for _, secret := range []string{"hunter2", "t0k3n", "0ldk3y"} {
+				if strings.Contains(printed, secret) {
+					t.Errorf("output shows the secret %s:\n%s", secret, printed)
+				}
+			}
+			if !strings.Contains(printed, "API_TOKEN=***") {
+				t.Errorf("output has no masked API_TOKEN:\n%s", printed)
+			}
+		})
+	}
+}
+
+func TestStopTimeoutPrecedence(t *testing.T) {
+	tests := []struct {
+		name        string
+		flag        int
+		stopTimeout string
+		want        string
+		logged      bool
+	}{
+		{"flag only", 30, `"StopTimeout": null`, "30", false},
+		{"StopTimeout only", -1, `"StopTimeout": 60`, "60", false},
+		{"both", 30, `"StopTimeout": 60`, "30", true},
+		{"both equal", 60, `"StopTimeout": 60`, "60", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, out := setupFlow(t)
+			f.inspect("web", strings.Replace(fixture(t, "many-flags"), `"StopTimeout": 60`, tt.stopTimeout, 1))
+			opts.stopTimeout = tt.flag
+
+			if result := processOne("web"); result.Status != statusRestarted {
+				t.Fatalf("status = %s (%s), want %s", result.Status, result.Error, statusRestarted)
+			}
+			f.call("docker", "stop", "-t", tt.want, "web")
+			if got := flagValues(f.call("docker", "run"), "--stop-timeout"); !slices.Equal(got, []string{tt.want}) {
+				t.Errorf("run --stop-timeout %q, want %s", got, tt.want)
+			}
+			logged := strings.Contains(out.String(), "instead of the container's own StopTimeout of 60s")
+			if logged != tt.logged {
+				t.Errorf("override logged = %v, want %v:\n%s", logged, tt.logged, out)
+			}
+		})
+	}
+}
+
+func TestCommitBeforeStop(t *testing.T) {
+	f, out := setupFlow(t)
+	f.inspect("web", fixture(t, "many-flags"))
+	opts.commitBefore = true
+
+	if result := processOne("web"); result.Status != statusRestarted {
+		t.Fatalf("status = %s (%s), want %s", result.Status, result.Error, statusRestarted)
+	}
+	commit := f.call("docker", "commit", "web")
+	if len(commit) != 4 || !strings.HasPrefix(commit[3], "web-snapshot:") {
+		t.Errorf("commit = %q, want web committed to web-snapshot:<timestamp>", commit)
+	}
+	if f.index("docker", "commit") > f.index("docker", "stop") {
+		t.Errorf("commit ran after the stop; calls:\n%s", f.dump())
+	}
+	if !strings.Contains(out.String(), commit[3]) {
+		t.Errorf("the snapshot reference %s was not printed:\n%s", commit[3], out)
+	}
+}
+
+func TestPreStopSignal(t *testing.T) {
+	f, _ := setupFlow(t)
+	f.inspect("web", fixture(t, "many-flags"))
+	opts.preStopSignal, opts.preStopWait = "SIGUSR1", 10*time.Millisecond
+
+	if result := processOne("web"); result.Status != statusRestarted {
+		t.Fatalf("status = %s (%s), want %s", result.Status, result.Error, statusRestarted)
+	}
+	kill := f.index("docker", "kill", "--signal", "SIGUSR1", "web")
+	if kill < 0 || kill > f.index("docker", "stop") {
+		t.Errorf("want docker kill --signal SIGUSR1 before the stop; calls:\n%s", f.dump())
+	}
+}
+
+func TestEmulatedPlatform(t *testing.T) {
+	f, _ := setupFlow(t)
+	f.inspect("web", fixture(t, "many-flags"))
+	f.answer("linux/arm64\n", "docker", "version", "--format")
+
+	if result := processOne("web"); result.Status != statusRestarted {
+		t.Fatalf("status = %s (%s), want %s", result.Status, result.Error, statusRestarted)
+	}
+	if pull := f.call("docker", "pull"); !slices.Equal(pull, []string{"docker", "pull", "--platform", "linux/amd64", "nginx:1.25"}) {
+		t.Errorf("pull = %q, want the amd64 image pulled", pull)
+	}
+	if got := flagValues(f.call("docker", "run"), "--platform"); !slices.Equal(got, []string{"linux/amd64"}) {
+		t.Errorf("run --platform %q, want linux/amd64", got)
+	}
+
+	// On a native host neither command names a platform.
+	f, _ = setupFlow(t)
+	f.inspect("web", fixture(t, "many-flags"))
+	processOne("web")
+	if slices.Contains(f.call("docker", "pull"), "--platform") || slices.Contains(f.call("docker", "run"), "--platform") {
+		t.Errorf("--platform given for a native image; calls:\n%s", f.dump())
+	}
+}
+
+func TestDryRunReportsDropped(t *testing.T) {
+	unmodeled := strings.Replace(fixture(t, "many-flags"), `"HostConfig": {`, `"HostConfig": {"PidMode": "host",`, 1)
+
+	t.Run("text", func(t *testing.T) {
+		f, out := setupFlow(t)
+		f.inspect("web", unmodeled)
+		opts.dryRun = true
+
+		if result := processOne("web"); result.Status != statusPlanned {
+			t.Fatalf("status = %s (%s), want %s", result.Status, result.Error, statusPlanned)
+		}
+		if !strings.Contains(out.String(), "would be dropped:\n  HostConfig.PidMode\n") {
+			t.Errorf("dropped fields not listed:\n%s", out)
+		}
+	})
+
+	t.Run("json", func(t *testing.T) {
+		f, _ := setupFlow(t)
+		f.inspect("web", unmodeled)
+		opts.dryRun, opts.json = true, true
+
+		var p plan
+		if err := json.Unmarshal([]byte(captureStdout(t, func() { processOne("web") })), &p); err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(p.Dropped, []string{"HostConfig.PidMode"}) {
+			t.Errorf("dropped = %q, want HostConfig.PidMode", p.Dropped)
+		}
+	})
+
+	t.Run("nothing to drop", func(t *testing.T) {
+		f, out := setupFlow(t)
+		f.inspect("web", fixture(t, "many-flags"))
+		opts.dryRun = true
+
+		processOne("web")
+		if strings.Contains(out.String(), "would be dropped") {
+			t.Errorf("dropped fields reported for a fully modeled container:\n%s", out)
+		}
+	})
+}
+
+func TestCancelLeavesContainerRunning(t *testing.T) {
+	f, _ := setupFlow(t)
+	f.inspect("web", fixture(t, "many-flags"))
+	opts.yes = false
+	opts.commitBefore = true
+	opts.backupDir = t.TempDir()
+	opts.labelFile = filepath.Join(t.TempDir(), "labels")
+	stdinReader.Reset(strings.NewReader("n\n"))
+
+	if result := processOne("web"); result.Status != statusCancelled {
+		t.Fatalf("status = %s (%s), want %s", result.Status, result.Error, statusCancelled)
+	}
+	for _, step := range []string{"commit", "stop", "kill", "rename", "rm", "run"} {
+		if f.index("docker", step) >= 0 {
+			t.Errorf("docker %s ran before the prompt was answered; calls:\n%s", step, f.dump())
+		}
+	}
+	if entries, _ := os.ReadDir(opts.backupDir); len(entries) > 0 {
+		t.Errorf("inspect backup written before the prompt was answered: %v", entries)
+	}
+	if _, err := os.Stat(opts.labelFile); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("label file written before the prompt was answered: %v", err)
+	}
+	if pendingFor(t, "web") {
+		t.Error("pending config recorded for a cancelled recreate")
+	}
+}
+
+func TestChangesBeforePrompt(t *testing.T) {
+	f, out := setupFlow(t)
+	f.inspect("web", fixture(t, "many-flags"))
+	opts.yes, opts.showChanges = false, true
+	stdinReader.Reset(strings.NewReader("y\n"))
+
+	if result := processOne("web"); result.Status != statusRestarted {
+		t.Fatalf("status = %s (%s), want %s", result.Status, result.Error, statusRestarted)
+	}
+	printed := out.String()
+	summary, prompt := strings.Index(printed, "image: nginx:1.25 (old -> new)"), strings.Index(printed, "Do you want to execute this command?")
+	if summary < 0 || prompt < 0 || summary > prompt {
+		t.Errorf("want the change summary before the prompt:\n%s", printed)
+	}
+}
+
+func TestShowChangesDefault(t *testing.T) {
+	tests := []struct {
+		args []string
+		want bool
+	}{
+		// Test output is not a terminal, so the default is off.
+		{[]string{"web"}, false},
+		{[]string{"--show-changes", "web"}, true},
+	}
+	for _, tt := range tests {
+		resetState(t)
+		if _, code := parseFlags(tt.args); code != 0 {
+			t.Fatalf("%q: exit code = %d", tt.args, code)
+		}
+		if opts.showChanges != tt.want {
+			t.Errorf("%q: showChanges = %v, want %v", tt.args, opts.showChanges, tt.want)
+		}
+	}
+}
+
+func TestStoppedContainer(t *testing.T) {
+	stopped := func(t *testing.T) string {
+		return strings.Replace(fixture(t, "many-flags"), `"Status": "running", "Running": true`, `"Status": "exited", "Running": false`, 1)
+	}
+
+	t.Run("recreated without docker stop", func(t *testing.T) {
+		f, out := setupFlow(t)
+		f.inspect("web", stopped(t))
+		opts.yes = false
+		stdinReader.Reset(strings.NewReader("y\ny\n"))
+
+		if result := processOne("web"); result.Status != statusRestarted {
+			t.Fatalf("status = %s (%s), want %s", result.Status, result.Error, statusRestarted)
+		}
+		if !strings.Contains(out.String(), "Container web is not running (status: exited)") {
+			t.Errorf("no warning about the stopped container:\n%s", out)
+		}
+		if f.index("docker", "stop") >= 0 || f.index("docker", "kill") >= 0 {
+			t.Errorf("a stopped container was stopped again; calls:\n%s", f.dump())
+		}
+		f.call("docker", "rename", "web", "web_drun_backup")
+		f.call("docker", "run")
+	})
+
+	t.Run("cancelled at the not-running prompt", func(t *testing.T) {
+		f, _ := setupFlow(t)
+		f.inspect("web", stopped(t))
+		opts.yes = false
+		stdinReader.Reset(strings.NewReader("n\n"))
+
+		if result := processOne("web"); result.Status != statusCancelled {
+			t.Fatalf("status = %s (%s), want %s", result.Status, result.Error, statusCancelled)
+		}
+		if f.index("docker", "pull") >= 0 || f.index("docker", "run") >= 0 {
+			t.Errorf("container recreated after cancelling; calls:\n%s", f.dump())
+		}
+	})
+}
+
+func TestLabelFile(t *testing.T) {
+	t.Run("written and passed as one flag", func(t *testing.T) {
+		f, _ := setupFlow(t)
+		f.inspect("web", fixture(t, "many-flags"))
+		dir := t.TempDir()
+		opts.labelFile = filepath.Join(dir, "{name}.labels")
+
+		if result := processOne("web"); result.Status != statusRestarted {
+			t.Fatalf("status = %s (%s), want %s", result.Status, result.Error, statusRestarted)
+		}
+		path := filepath.Join(dir, "web.labels")
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := "app=web\ntraefik.enable=true\n"; string(data) != want {
+			t.Errorf("label file = %q, want %q", data, want)
+		}
+		run := f.call("docker", "run")
+		if got := flagValues(run, "--label-file"); !slices.Equal(got, []string{path}) {
+			t.Errorf("--label-file %q, want %s", got, path)
+		}
+		if got := flagValues(run, "-l"); got != nil {
+			t.Errorf("-l %q next to --label-file", got)
+		}
+	})
+
+	for _, mode := range []string{"dry-run", "output"} {
+		t.Run("not written under --"+mode, func(t *testing.T) {
+			f, messages := setupFlow(t)
+			f.inspect("web", fixture(t, "many-flags"))
+			opts.labelFile = filepath.Join(t.TempDir(), "web.labels")
+
+			out := captureStdout(t, func() {
+				if mode == "dry-run" {
+					opts.dryRun = true
+					processOne("web")
+				} else {
+					opts.output = "argv"
+					runUpdate([]string{"web"})
+				}
+			})
+			printed := out + messages.String()
+			if strings.Contains(printed, "--label-file") {
+				t.Errorf("printed a --label-file that is never written:\n%s", printed)
+			}
+			if !strings.Contains(printed, "app=web") {
+				t.Errorf("labels missing from the printed command:\n%s", printed)
+			}
+			if _, err := os.Stat(opts.labelFile); !errors.Is(err, os.ErrNotExist) {
+				t.Errorf("label file written under --%s: %v", mode, err)
+			}
+		})
+	}
+}
+
+func TestDryRunRollbackPreview(t *testing.T) {
+	tests := []struct {
+		name    string
+		running bool
+		want    []string
+	}{
+		{"running", true, []string{"rm -f web", "rename web_drun_backup -> web", "start web"}},
+		{"stopped", false, []string{"rm -f web", "rename web_drun_backup -> web"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, out := setupFlow(t)
+			raw := fixture(t, "many-flags")
+			if !tt.running {
+				raw = strings.Replace(raw, `"Status": "running", "Running": true`, `"Status": "exited", "Running": false`, 1)
+			}
+			f.inspect("web", raw)
+			opts.dryRun, opts.healthTimeout = true, 30*time.Second
+
+			if result := processOne("web"); result.Status != statusPlanned {
+				t.Fatalf("status = %s (%s), want %s", result.Status, result.Error, statusPlanned)
+			}
+			printed := out.String()
+			forward := strings.Index(printed, "rename web -> web_drun_backup")
+			rollback := strings.Index(printed, "If a later step fails, the previous container is restored:")
+			if forward < 0 || rollback < forward {
+				t.Fatalf("want the forward plan followed by the rollback:\n%s", printed)
+			}
+			for i, step := range tt.want {
+				if !strings.Contains(printed[rollback:], fmt.Sprintf("%d. %s\n", i+1, step)) {
+					t.Errorf("rollback has no step %d %q:\n%s", i+1, step, printed[rollback:])
+				}
+			}
+			if !tt.running && strings.Contains(printed[rollback:], "start web") {
+				t.Errorf("rollback restarts a container that was not running:\n%s", printed[rollback:])
+			}
+		})
+	}
+}
+
+func TestBatchDropsInteractiveFlags(t *testing.T) {
+	f, out := setupFlow(t)
+	f.inspect("shell", fixture(t, "interactive"))
+	f.inspect("web", fixture(t, "many-flags"))
+
+	if code := runUpdate([]string{"shell", "web"}); code != 0 {
+		t.Fatalf("exit code = %d; calls:\n%s", code, f.dump())
+	}
+	run := f.call("docker", "run", "*", "--name", "shell")
+	for _, flag := range []string{"-it", "-t", "-i"} {
+		if slices.Contains(run, flag) {
+			t.Errorf("batch run of an interactive container passes %s: %q", flag, run)
+		}
+	}
+	if run[2] != "-d" {
+		t.Errorf("batch run of an interactive container is not detached: %q", run)
+	}
+	if !strings.Contains(out.String(), "Container shell is interactive; dropping -it") {
+		t.Errorf("no warning about dropping -it:\n%s", out)
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// A container without a healthcheck counts as started once it has stayed
+// up for startupGrace; one with a healthcheck is polled every
+// healthPollInterval until it reports healthy.
+const (
+	startupGrace       = 2 * time.Second
+	healthPollInterval = time.Second
+)
+
+// containerStatus is the part of docker inspect that tells whether a
+// container came up.
+type containerStatus struct {
+	State struct {
+		Running  bool   `json:"Running"`
+		Status   string `json:"Status"`
+		ExitCode int    `json:"ExitCode"`
+		Health   *struct {
+			Status string `json:"Status"`
+		} `json:"Health"`
+	} `json:"State"`
+}
+
+// inspectStatus reads the container's state fresh from the daemon; the
+// inspect cache would keep returning the first answer.
+func inspectStatus(containerName string) (*containerStatus, error) {
+	output, err := dockerCommand("inspect", "--format", `{"State":{{json .State}}}`, containerName).Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect container %s: %v", containerName, err)
+	}
+	var status containerStatus
+	if err := json.Unmarshal(output, &status); err != nil {
+		return nil, fmt.Errorf("failed to parse state of %s: %v", containerName, err)
+	}
+	return &status, nil
+}
+
+// waitForContainer checks that a freshly started container actually came
+// up: with a healthcheck it must report healthy within timeout, without one
+// it must still be running after startupGrace. It fails as soon as the
+// container exits or turns unhealthy. The returned word says which check
+// passed, for the success message.
+func waitForContainer(containerName string, timeout time.Duration) (string, error) {
+	start := time.Now()
+	for {
+		status, err := inspectStatus(containerName)
+		if err != nil {
+			return "", err
+		}
+		if !status.State.Running {
+			return "", fmt.Errorf("container %s is %s (exit code %d) instead of running", containerName, status.State.Status, status.State.ExitCode)
+		}
+
+		elapsed := time.Since(start)
+		if status.State.Health == nil {
+			if elapsed >= min(startupGrace, timeout) {
+				return "running", nil
+			}
+		} else {
+			switch status.State.Health.Status {
+			case "healthy":
+				return "healthy", nil
+			case "unhealthy":
+				return "", fmt.Errorf("container %s is unhealthy", containerName)
+			}
+			if elapsed >= timeout {
+				return "", fmt.Errorf("container %s did not become healthy within %s (health: %s)", containerName, timeout, status.State.Health.Status)
+			}
+		}
+		time.Sleep(healthPollInterval)
+	}
+}
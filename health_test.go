@@ -0,0 +1,41 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWaitForContainer(t *testing.T) {
+	tests := []struct {
+		name    string
+		state   string
+		timeout time.Duration
+		want    string
+		err     string
+	}{
+		{"healthy", `{"Running":true,"Status":"running","Health":{"Status":"healthy"}}`, time.Minute, "healthy", ""},
+		{"unhealthy", `{"Running":true,"Status":"running","Health":{"Status":"unhealthy"}}`, time.Minute, "", "is unhealthy"},
+		{"exited", `{"Running":false,"Status":"exited","ExitCode":137}`, time.Minute, "", "is exited (exit code 137)"},
+		{"running without a healthcheck", `{"Running":true,"Status":"running"}`, 100 * time.Millisecond, "running", ""},
+		{"health timeout", `{"Running":true,"Status":"running","Health":{"Status":"starting"}}`, 500 * time.Millisecond, "", "did not become healthy within 500ms (health: starting)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetState(t)
+			f := newFakeDocker(t)
+			f.answer(`{"State":`+tt.state+`}`, "docker", "inspect", "--format", `{"State":{{json .State}}}`, "web")
+
+			got, err := waitForContainer("web", tt.timeout)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("err = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("waitForContainer = %q, %v, want %q", got, err, tt.want)
+			}
+		})
+	}
+}
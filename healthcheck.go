@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"time"
+)
+
+// Healthcheck mirrors Config.Healthcheck. Durations are reported in
+// nanoseconds and zero means "use the default".
+type Healthcheck struct {
+	Test          []string `json:"Test"`
+	Interval      int64    `json:"Interval"`
+	Timeout       int64    `json:"Timeout"`
+	StartPeriod   int64    `json:"StartPeriod"`
+	StartInterval int64    `json:"StartInterval"`
+	Retries       int      `json:"Retries"`
+}
+
+// overriddenHealthcheck returns the container's healthcheck if it was set
+// at run time, and nil if it is the image's HEALTHCHECK, which the new
+// container inherits again (possibly updated by the new image). Without the
+// image config the container's healthcheck is kept.
+func overriddenHealthcheck(info *ContainerInfo) *Healthcheck {
+	hc := info.Config.Healthcheck
+	if hc == nil || len(hc.Test) == 0 {
+		return nil
+	}
+	image, err := imageDefaults(info)
+	if err != nil {
+		printWarning("Could not read the image healthcheck, keeping the container's: %v\n", err)
+		return hc
+	}
+	if image.Healthcheck != nil && reflect.DeepEqual(*hc, *image.Healthcheck) {
+		return nil
+	}
+	return hc
+}
+
+// healthcheckArgs renders a healthcheck as docker run flags. Test comes in
+// three forms: ["NONE"] disables the image's check, ["CMD-SHELL", cmd] is
+// what --health-cmd creates, and ["CMD", argv...] is the exec form only a
+// Dockerfile or the API can set. --health-cmd is always run through a
+// shell, so the exec form is re-emitted with each argument shell-quoted to
+// keep the same argv.
+func healthcheckArgs(hc *Healthcheck) []string {
+	if hc == nil || len(hc.Test) == 0 {
+		return nil
+	}
+
+	var args []string
+	switch hc.Test[0] {
+	case "NONE":
+		return []string{"--no-healthcheck"}
+	case "CMD-SHELL":
+		if len(hc.Test) < 2 {
+			printWarning("Dropping healthcheck with an empty CMD-SHELL command\n")
+			return nil
+		}
+		args = append(args, "--health-cmd", hc.Test[1])
+	case "CMD":
+		if len(hc.Test) < 2 {
+			printWarning("Dropping healthcheck with an empty CMD command\n")
+			return nil
+		}
+		args = append(args, "--health-cmd", formatCommand(hc.Test[1:]))
+	default:
+		printWarning("Dropping healthcheck with unknown test type %q\n", hc.Test[0])
+		return nil
+	}
+
+	for _, d := range []struct {
+		flag  string
+		value int64
+	}{
+		{"--health-interval", hc.Interval},
+		{"--health-timeout", hc.Timeout},
+		{"--health-start-period", hc.StartPeriod},
+		{"--health-start-interval", hc.StartInterval},
+	} {
+		if d.value > 0 {
+			args = append(args, d.flag, time.Duration(d.value).String())
+		}
+	}
+	if hc.Retries > 0 {
+		args = append(args, "--health-retries", fmt.Sprintf("%d", hc.Retries))
+	}
+	return args
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestHealthcheckArgs(t *testing.T) {
+	tests := []struct {
+		name string
+		hc   *Healthcheck
+		want []string
+	}{
+		{"none", &Healthcheck{Test: []string{"NONE"}}, []string{"--no-healthcheck"}},
+		{"shell form", &Healthcheck{Test: []string{"CMD-SHELL", "curl -f http://localhost/ || exit 1"}},
+			[]string{"--health-cmd", "curl -f http://localhost/ || exit 1"}},
+		{"exec form", &Healthcheck{Test: []string{"CMD", "pg_isready", "-U", "app user"}},
+			[]string{"--health-cmd", "pg_isready -U 'app user'"}},
+		{"timings", &Healthcheck{Test: []string{"CMD", "true"}, Interval: int64(30 * time.Second), Timeout: int64(5 * time.Second), StartPeriod: int64(time.Minute), Retries: 3},
+			[]string{"--health-cmd", "true", "--health-interval", "30s", "--health-timeout", "5s", "--health-start-period", "1m0s", "--health-retries", "3"}},
+		{"empty CMD", &Healthcheck{Test: []string{"CMD"}}, nil},
+		{"unknown type", &Healthcheck{Test: []string{"HTTP", "/health"}}, nil},
+		{"unset", nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetState(t)
+			if got := healthcheckArgs(tt.hc); !slices.Equal(got, tt.want) {
+				t.Errorf("healthcheckArgs = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOverriddenHealthcheck(t *testing.T) {
+	const container = `"Healthcheck":{"Test":["CMD-SHELL","curl -f http://localhost/"],"Interval":30000000000}`
+	tests := []struct {
+		name  string
+		image *imageConfig
+		want  []string
+	}{
+		{"image without a healthcheck", &imageConfig{}, []string{"--health-cmd", "curl -f http://localhost/", "--health-interval", "30s"}},
+		{"inherited from the image", &imageConfig{Healthcheck: &Healthcheck{Test: []string{"CMD-SHELL", "curl -f http://localhost/"}, Interval: int64(30 * time.Second)}},
+			nil},
+		{"interval overridden", &imageConfig{Healthcheck: &Healthcheck{Test: []string{"CMD-SHELL", "curl -f http://localhost/"}}},
+			[]string{"--health-cmd", "curl -f http://localhost/", "--health-interval", "30s"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetState(t)
+			info := testContainer(t, container, "")
+			info.image = tt.image
+
+			if got := healthcheckArgs(overriddenHealthcheck(info)); !slices.Equal(got, tt.want) {
+				t.Errorf("healthcheck flags = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
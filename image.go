@@ -0,0 +1,97 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+// imageRef is a parsed image reference: [domain/]path[:tag][@digest].
+// Domain is empty when the reference relies on Docker Hub being implied.
+type imageRef struct {
+	Domain string
+	Path   string
+	Tag    string
+	Digest string
+}
+
+// parseImageRef splits an image reference the way docker does: the first
+// path component is a registry host only if it contains a "." or ":" or is
+// "localhost", so "registry:5000/app:v1" keeps its port out of the tag.
+func parseImageRef(ref string) imageRef {
+	var r imageRef
+	ref, r.Digest, _ = strings.Cut(ref, "@")
+
+	if first, rest, ok := strings.Cut(ref, "/"); ok && (strings.ContainsAny(first, ".:") || first == "localhost") {
+		r.Domain = first
+		ref = rest
+	}
+
+	if i := strings.LastIndex(ref, ":"); i > strings.LastIndex(ref, "/") {
+		r.Path, r.Tag = ref[:i], ref[i+1:]
+	} else {
+		r.Path = ref
+	}
+	return r
+}
+
+func (r imageRef) String() string {
+	ref := r.Path
+	if r.Domain != "" {
+		ref = r.Domain + "/" + ref
+	}
+	if r.Tag != "" {
+		ref += ":" + r.Tag
+	}
+	if r.Digest != "" {
+		ref += "@" + r.Digest
+	}
+	return ref
+}
+
+func (r imageRef) isDockerHub() bool {
+	switch r.Domain {
+	case "", "docker.io", "index.docker.io", "registry-1.docker.io":
+		return true
+	}
+	return false
+}
+
+// withMirror moves a reference onto a registry mirror, keeping the
+// repository, tag and digest. Docker Hub's implicit "library/" namespace is
+// made explicit since the mirror has no such default. Non-Hub references are
+// only rewritten when all is set.
+func (r imageRef) withMirror(mirror string, all bool) imageRef {
+	if r.isDockerHub() {
+		if !strings.Contains(r.Path, "/") {
+			r.Path = "library/" + r.Path
+		}
+	} else if !all {
+		return r
+	}
+	r.Domain = mirror
+	return r
+}
+
+// targetImage is the image reference the recreated container is pulled and
+// run from, after any command line rewrites of the inspected image.
+func targetImage(info *ContainerInfo) string {
+	ref := info.Config.Image
+	if opts.tag != "" {
+		ref = parseImageRef(ref).withTag(opts.tag).String()
+	}
+	if opts.registryMirror != "" {
+		ref = parseImageRef(ref).withMirror(opts.registryMirror, opts.mirrorAll).String()
+	}
+	return ref
+}
+
+// imageTag matches the tags docker accepts.
+var imageTag = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_.-]{0,127}$`)
+
+// withTag points a reference at another tag of the same repository. A
+// pinned digest is dropped, it would otherwise win over the new tag.
+func (r imageRef) withTag(tag string) imageRef {
+	r.Tag = tag
+	r.Digest = ""
+	return r
+}
@@ -0,0 +1,26 @@
+package main
+
+import "testing"
+
+func TestWithMirror(t *testing.T) {
+	tests := []struct {
+		ref  string
+		all  bool
+		want string
+	}{
+		{"nginx:1.25", false, "mirror.internal/library/nginx:1.25"},
+		{"nginx", false, "mirror.internal/library/nginx"},
+		{"docker.io/library/nginx:1.25", false, "mirror.internal/library/nginx:1.25"},
+		{"index.docker.io/nginx:1.25", false, "mirror.internal/library/nginx:1.25"},
+		{"bitnami/redis:7", false, "mirror.internal/bitnami/redis:7"},
+		{"nginx:1.25@sha256:abc", false, "mirror.internal/library/nginx:1.25@sha256:abc"},
+		{"ghcr.io/org/app:1", false, "ghcr.io/org/app:1"},
+		{"ghcr.io/org/app:1", true, "mirror.internal/org/app:1"},
+		{"localhost:5000/app:v1", false, "localhost:5000/app:v1"},
+	}
+	for _, tt := range tests {
+		if got := parseImageRef(tt.ref).withMirror("mirror.internal", tt.all).String(); got != tt.want {
+			t.Errorf("withMirror(%q, all=%v) = %q, want %q", tt.ref, tt.all, got, tt.want)
+		}
+	}
+}
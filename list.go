@@ -0,0 +1,73 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+)
+
+// runList implements `drun list`, which shows running containers and
+// whether their image is behind the registry.
+func runList(args []string) int {
+	fs := flag.NewFlagSet("drun list", flag.ExitOnError)
+	staleOnly := fs.Bool("stale-only", false, "only show containers whose image differs from the latest registry digest")
+	quiet := fs.Bool("quiet", false, "only print container names")
+	addRuntimeFlags(fs)
+	fs.Parse(args)
+
+	names, err := runningContainerNames()
+	if err != nil {
+		printError("Failed to list containers: %v\n", err)
+		return 1
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	if !*quiet {
+		fmt.Fprintln(w, "NAME\tIMAGE\tSTATUS")
+	}
+	for _, name := range names {
+		info, err := getContainerInfo(name)
+		if err != nil {
+			if !*quiet {
+				printWarning("Skipping %s: %v\n", name, err)
+			}
+			continue
+		}
+
+		status := "current"
+		current, _, err := imageFreshness(info)
+		switch {
+		case err != nil:
+			status = "unknown"
+		case !current:
+			status = "stale"
+		}
+		if *staleOnly && status != "stale" {
+			continue
+		}
+
+		if *quiet {
+			fmt.Fprintln(w, name)
+		} else {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", name, info.Config.Image, status)
+		}
+	}
+	w.Flush()
+	return 0
+}
+
+// runningContainerNames lists running containers, optionally narrowed by
+// docker ps --filter expressions such as "label=tier=web".
+func runningContainerNames(filters ...string) ([]string, error) {
+	args := []string{"ps", "--format", "{{.Names}}"}
+	for _, filter := range filters {
+		args = append(args, "--filter", filter)
+	}
+	output, err := dockerCommand(args...).Output()
+	if err != nil {
+		return nil, err
+	}
+	return strings.Fields(string(output)), nil
+}
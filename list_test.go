@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// fakeRegistry sets up running containers web (stale), db (current) and
+// cache (registry unreachable).
+func fakeRegistry(t *testing.T) *fakeDocker {
+	f := newFakeDocker(t)
+	f.answer("web\ndb\ncache\n", "docker", "ps", "--format", "{{.Names}}")
+	for _, c := range []struct{ name, image, id string }{
+		{"web", "nginx:1.25", "sha256:web"},
+		{"db", "postgres:16", "sha256:db"},
+		{"cache", "redis:7", "sha256:cache"},
+	} {
+		f.inspect(c.name, fmt.Sprintf(`{"Id":"%s1","Name":"/%s","Image":"%s","Config":{"Image":"%s"},"HostConfig":{}}`, c.name, c.name, c.id, c.image))
+	}
+	f.answer(`{"digest":"sha256:nginx-latest"}`, "docker", "buildx", "imagetools", "inspect", "--format", "{{json .Manifest}}", "nginx:1.25")
+	f.answer(`{"digest":"sha256:pg-latest"}`, "docker", "buildx", "imagetools", "inspect", "--format", "{{json .Manifest}}", "postgres:16")
+	f.fail("ERROR: failed to resolve", "docker", "buildx", "imagetools", "inspect", "--format", "{{json .Manifest}}", "redis:7")
+	f.answer(`["nginx@sha256:nginx-older"]`, "docker", "image", "inspect", "--format", "{{json .RepoDigests}}", "sha256:web")
+	f.answer(`["postgres@sha256:pg-latest"]`, "docker", "image", "inspect", "--format", "{{json .RepoDigests}}", "sha256:db")
+	return f
+}
+
+func TestListStatus(t *testing.T) {
+	resetState(t)
+	fakeRegistry(t)
+
+	out := captureStdout(t, func() {
+		if code := runList(nil); code != 0 {
+			t.Errorf("exit code = %d", code)
+		}
+	})
+	want := map[string]string{"web": "stale", "db": "current", "cache": "unknown"}
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("want a header and 3 rows:\n%s", out)
+	}
+	for _, line := range lines[1:] {
+		fields := strings.Fields(line)
+		if len(fields) != 3 || want[fields[0]] != fields[2] {
+			t.Errorf("row %q, want status %s", line, want[fields[0]])
+		}
+	}
+}
+
+func TestListStaleOnlyQuiet(t *testing.T) {
+	resetState(t)
+	fakeRegistry(t)
+
+	out := captureStdout(t, func() {
+		if code := runList([]string{"--stale-only", "--quiet"}); code != 0 {
+			t.Errorf("exit code = %d", code)
+		}
+	})
+	if out != "web\n" {
+		t.Errorf("output = %q, want only the stale container", out)
+	}
+}
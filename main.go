@@ -2,15 +2,25 @@ package main
 
 import (
 	"bufio"
+	"bytes"
+	"cmp"
+	"context"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
-	"log"
+	"io"
 	"os"
 	"os/exec"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 )
 
-// Color constants for terminal output
+// Color constants for terminal output, applied through paint
 const (
 	ColorReset  = "\033[0m"
 	ColorRed    = "\033[31m"
@@ -23,50 +33,162 @@ const (
 	ColorBold   = "\033[1m"
 )
 
+// messages is where the print helpers write. Output formats that put a
+// document on stdout move the messages to stderr so the document stays
+// machine-readable, and --json discards them in favour of its own report.
+var messages io.Writer = os.Stdout
+
+// commandOutput receives the stdout of the commands drun runs, such as the
+// docker pull progress. It moves to stderr together with the messages.
+var commandOutput io.Writer = os.Stdout
+
 // Helper functions for colored output
 func printInfo(format string, args ...interface{}) {
-	fmt.Printf(ColorBlue+"[INFO]"+ColorReset+" "+format, args...)
+	fmt.Fprintf(messages, paint(messages, ColorBlue, "[INFO]")+" "+format, args...)
 }
 
 func printSuccess(format string, args ...interface{}) {
-	fmt.Printf(ColorGreen+"[SUCCESS]"+ColorReset+" "+format, args...)
+	fmt.Fprintf(messages, paint(messages, ColorGreen, "[SUCCESS]")+" "+format, args...)
 }
 
 func printWarning(format string, args ...interface{}) {
-	fmt.Printf(ColorYellow+"[WARNING]"+ColorReset+" "+format, args...)
+	if opts.json {
+		printRecord("warning", format, args...)
+		return
+	}
+	fmt.Fprintf(messages, paint(messages, ColorYellow, "[WARNING]")+" "+format, args...)
 }
 
 func printError(format string, args ...interface{}) {
-	fmt.Printf(ColorRed+"[ERROR]"+ColorReset+" "+format, args...)
+	if opts.json {
+		printRecord("error", format, args...)
+		return
+	}
+	fmt.Fprintf(messages, paint(messages, ColorRed, "[ERROR]")+" "+format, args...)
+}
+
+// printRecord writes a warning or error as a JSON line on stderr, which is
+// how --json reports them.
+func printRecord(level, format string, args ...interface{}) {
+	record := struct {
+		Level   string `json:"level"`
+		Message string `json:"message"`
+	}{level, strings.TrimSpace(fmt.Sprintf(format, args...))}
+	json.NewEncoder(os.Stderr).Encode(record)
 }
 
 func printCommand(command string) {
-	fmt.Printf(ColorCyan+"Generated command:"+ColorReset+"\n")
-	fmt.Printf(ColorBold+"%s"+ColorReset+"\n\n", command)
+	fmt.Fprintln(messages, paint(messages, ColorCyan, "Generated command:"))
+	fmt.Fprintf(messages, "%s\n\n", paint(messages, ColorBold, command))
 }
 
+// printPrompt always reaches the user, even in --json mode where the other
+// messages are discarded.
 func printPrompt(prompt string) {
-	fmt.Printf(ColorYellow+prompt+ColorReset)
+	w := messages
+	if opts.json {
+		w = os.Stderr
+	}
+	fmt.Fprint(w, paint(w, ColorYellow, prompt))
 }
 
 type ContainerInfo struct {
-	Config struct {
+	ID           string `json:"Id"`
+	ImageID      string `json:"Image"`
+	RestartCount int    `json:"RestartCount"`
+	Config       struct {
 		Image string   `json:"Image"`
 		Cmd   []string `json:"Cmd"`
-		Env   []string `json:"Env"`
+		// Entrypoint is the effective entrypoint: the image's unless
+		// docker run --entrypoint overrode it.
+		Entrypoint []string          `json:"Entrypoint"`
+		User       string            `json:"User"`
+		WorkingDir string            `json:"WorkingDir"`
+		Env        []string          `json:"Env"`
+		Labels     map[string]string `json:"Labels"`
+
+		Healthcheck *Healthcheck `json:"Healthcheck"`
+		StopTimeout *int         `json:"StopTimeout"`
+
+		Tty         bool `json:"Tty"`
+		OpenStdin   bool `json:"OpenStdin"`
+		AttachStdin bool `json:"AttachStdin"`
 	} `json:"Config"`
 	HostConfig struct {
-		Binds           []string          `json:"Binds"`
-		PortBindings    map[string][]Port `json:"PortBindings"`
-		RestartPolicy   RestartPolicy     `json:"RestartPolicy"`
-		NetworkMode     string            `json:"NetworkMode"`
-		Privileged      bool              `json:"Privileged"`
-		PublishAllPorts bool              `json:"PublishAllPorts"`
+		Binds             []string          `json:"Binds"`
+		VolumesFrom       []string          `json:"VolumesFrom"`
+		Links             []string          `json:"Links"`
+		ContainerIDFile   string            `json:"ContainerIDFile"`
+		PortBindings      map[string][]Port `json:"PortBindings"`
+		RestartPolicy     RestartPolicy     `json:"RestartPolicy"`
+		NetworkMode       string            `json:"NetworkMode"`
+		Privileged        bool              `json:"Privileged"`
+		ReadonlyRootfs    bool              `json:"ReadonlyRootfs"`
+		PublishAllPorts   bool              `json:"PublishAllPorts"`
+		OomScoreAdj       int               `json:"OomScoreAdj"`
+		CapAdd            []string          `json:"CapAdd"`
+		CapDrop           []string          `json:"CapDrop"`
+		GroupAdd          []string          `json:"GroupAdd"`
+		Annotations       map[string]string `json:"Annotations"`
+		ExtraHosts        []string          `json:"ExtraHosts"`
+		Dns               []string          `json:"Dns"`
+		DnsSearch         []string          `json:"DnsSearch"`
+		DnsOptions        []string          `json:"DnsOptions"`
+		DeviceCgroupRules []string          `json:"DeviceCgroupRules"`
+		Tmpfs             map[string]string `json:"Tmpfs"`
+		Sysctls           map[string]string `json:"Sysctls"`
+		NanoCpus          int64             `json:"NanoCpus"`
+		CpuQuota          int64             `json:"CpuQuota"`
+		CpuPeriod         int64             `json:"CpuPeriod"`
+		CpuShares         int64             `json:"CpuShares"`
+		Memory            int64             `json:"Memory"`
+		MemorySwap        *int64            `json:"MemorySwap"`
+		Ulimits           []Ulimit          `json:"Ulimits"`
+		LogConfig         LogConfig         `json:"LogConfig"`
+		Devices           []Device          `json:"Devices"`
 	} `json:"HostConfig"`
 	NetworkSettings struct {
 		Networks map[string]NetworkInfo `json:"Networks"`
 	} `json:"NetworkSettings"`
+	// Mounts lists every mount of the container, including named volumes
+	// and --mount mounts that HostConfig.Binds does not show.
+	Mounts []Mount `json:"Mounts"`
+	State  struct {
+		Status    string `json:"Status"`
+		Running   bool   `json:"Running"`
+		StartedAt string `json:"StartedAt"`
+	} `json:"State"`
 	Name string `json:"Name"`
+
+	// raw is the unparsed inspect object, kept for checks that need to see
+	// fields drun does not model.
+	raw json.RawMessage
+	// platform is set when the image runs under emulation and must be
+	// pulled and run for that platform rather than the native one.
+	platform string
+	// labels caches runLabels.
+	labels map[string]string
+	// image caches imageDefaults.
+	image *imageConfig
+}
+
+type Mount struct {
+	Type        string `json:"Type"`
+	Name        string `json:"Name"`
+	Source      string `json:"Source"`
+	Destination string `json:"Destination"`
+	RW          bool   `json:"RW"`
+}
+
+type LogConfig struct {
+	Type   string            `json:"Type"`
+	Config map[string]string `json:"Config"`
+}
+
+type Device struct {
+	PathOnHost        string `json:"PathOnHost"`
+	PathInContainer   string `json:"PathInContainer"`
+	CgroupPermissions string `json:"CgroupPermissions"`
 }
 
 type Port struct {
@@ -79,182 +201,1984 @@ type RestartPolicy struct {
 	MaximumRetryCount int    `json:"MaximumRetryCount"`
 }
 
+// Ulimit is one entry of HostConfig.Ulimits. Unlimited is reported as -1,
+// which docker run also accepts on input.
+type Ulimit struct {
+	Name string `json:"Name"`
+	Soft int64  `json:"Soft"`
+	Hard int64  `json:"Hard"`
+}
+
 type NetworkInfo struct {
-	NetworkID string `json:"NetworkID"`
+	NetworkID string   `json:"NetworkID"`
+	Aliases   []string `json:"Aliases"`
 }
 
-func main() {
-	if len(os.Args) < 2 {
-		log.Fatal("Usage: drun <container_name>")
+// options holds the command line flags shared by the whole run.
+type options struct {
+	dryRun                bool
+	json                  bool
+	defaultCaps           string
+	keepNameRetry         bool
+	nameReleaseWindow     time.Duration
+	summaryJSON           string
+	stateFile             string
+	showChanges           bool
+	strict                bool
+	registryMirror        string
+	tag                   string
+	mirrorAll             bool
+	output                string
+	profileLabel          string
+	assumeRunning         bool
+	verboseInspect        bool
+	inspectCache          bool
+	showSecrets           bool
+	labelFile             string
+	envFile               string
+	labelOrder            string
+	all                   bool
+	stdin                 bool
+	failFast              bool
+	yes                   bool
+	confirmMessage        string
+	configPath            string
+	group                 string
+	minUptime             time.Duration
+	trackDigest           bool
+	stopDeadline          time.Duration
+	stopTimeout           int
+	pullTimeout           time.Duration
+	pullConcurrency       int
+	pullRetries           int
+	pullBudget            time.Duration
+	runTimeout            time.Duration
+	noPull                bool
+	force                 bool
+	minFreeSpace          sizeFlag
+	recordCommand         bool
+	maskSecrets           bool
+	noColor               bool
+	commitBefore          bool
+	backupDir             string
+	createMissingNetworks bool
+	noNetworkRecreate     bool
+	preStopSignal         string
+	preStopWait           time.Duration
+	runtime               string
+	host                  string
+	preferRawCPU          bool
+	verifySignature       bool
+	smokeTest             string
+	smokeTestOnHost       bool
+	healthTimeout         time.Duration
+	verifierCmd           string
+
+	// batch is set when more than one container is handled in this run.
+	batch bool
+	// groupConfirmed is set once the user has approved a whole --group.
+	groupConfirmed bool
+	// envFileEntries is the parsed --env-file.
+	envFileEntries *envFileEntries
+	// prePulled holds the images pulled up front by --pull-concurrency,
+	// keyed by prePullKey.
+	prePulled map[string]bool
+}
+
+var opts options
+
+// newFlagSet registers the flags of the default update flow, which the
+// recreate subcommand shares. Registering them sets opts to the defaults.
+func newFlagSet() *flag.FlagSet {
+	fs := flag.NewFlagSet("drun", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: drun [flags] <container_name>...\n       drun [flags] --all\n       drun [flags] --group LABEL=VALUE\n       drun recreate [flags] <container_name>...\n       drun list [-H URL] [--runtime BINARY] [--stale-only] [--quiet]\n       drun check [-H URL] [--runtime BINARY] <container_name>...\n       drun diff-image [-H URL] [--runtime BINARY] <container_name>\n       drun config schema\n       drun completion bash|zsh|fish\n       drun self-update [--check] [--force]\n\nFlags:\n")
+		fs.PrintDefaults()
 	}
+	fs.BoolVar(&opts.noPull, "no-pull", false, "recreate from the local image without contacting the registry, e.g. when offline")
+	fs.BoolVar(&opts.noPull, "skip-pull", false, "alias for --no-pull, e.g. when retrying after the pull already succeeded")
+	fs.BoolVar(&opts.force, "force", false, "recreate even when the pulled image is the one the container already runs")
+	fs.BoolVar(&opts.inspectCache, "inspect-cache", true, "reuse a container's inspect output within a run until drun changes the container")
+	fs.BoolVar(&opts.dryRun, "dry-run", false, "print the planned actions without touching the container")
+	fs.BoolVar(&opts.json, "json", false, "print a JSON report of the run (or the --dry-run plan) instead of the usual messages")
+	fs.StringVar(&opts.defaultCaps, "default-caps", strings.Join(dockerDefaultCaps, ","), "comma-separated default capability set of the daemon")
+	fs.BoolVar(&opts.keepNameRetry, "keep-name-on-conflict", false, "retry docker run while the old container name is still being released")
+	fs.DurationVar(&opts.nameReleaseWindow, "name-conflict-timeout", 10*time.Second, "how long --keep-name-on-conflict keeps retrying")
+	fs.StringVar(&opts.summaryJSON, "summary-json", "", "write an aggregate JSON summary of all containers to `PATH` (- for stdout)")
+	fs.StringVar(&opts.stateFile, "state-file", defaultStatePath(), "where drun records the last generated command per container")
+	fs.BoolVar(&opts.showChanges, "show-changes", false, "summarize what changed since the last recorded run before prompting (default on when stdout is a terminal)")
+	fs.BoolVar(&opts.strict, "strict", false, "fail if the container uses config fields drun would silently drop")
+	fs.StringVar(&opts.tag, "tag", "", "pull and run `TAG` of the container's image repository instead of its current tag, e.g. to move myapp:1.2 to 1.3")
+	fs.StringVar(&opts.registryMirror, "registry-mirror", "", "pull and run Docker Hub images through the registry mirror `HOST`")
+	fs.BoolVar(&opts.mirrorAll, "mirror-all", false, "apply --registry-mirror to images from every registry, not just Docker Hub")
+	fs.StringVar(&opts.profileLabel, "profile-label", defaultProfileLabel, "container `LABEL` whose comma-separated value becomes the service's profiles in --output compose")
+	fs.StringVar(&opts.output, "output", "", "print the container configuration in another `FORMAT` instead of recreating it (compose, spec, terraform, argv)")
+	fs.BoolVar(&opts.assumeRunning, "assume-running", false, "skip the running-state preflight and always stop the container first")
+	fs.BoolVar(&opts.verboseInspect, "verbose-inspect", false, "print the parsed container configuration before generating the command")
+	fs.BoolVar(&opts.showSecrets, "show-secrets", false, "do not redact secret-looking env values in printed output")
+	fs.StringVar(&opts.envFile, "env-file", "", "emit --env-file `PATH` and leave out the env vars that file sets instead of inlining them")
+	fs.StringVar(&opts.labelFile, "label-file", "", "write the container labels to `PATH` and emit a single --label-file flag ({name} expands to the container name)")
+	fs.StringVar(&opts.labelOrder, "label-order", "sorted", "emit labels `sorted` by key or in their original inspect order")
+	fs.StringVar(&opts.configPath, "config", "", "read settings from `PATH` instead of ./.drun.json or ~/.drun.json")
+	fs.StringVar(&opts.confirmMessage, "confirm-message", "", "`TEXT` printed before the confirmation prompt, e.g. a production warning")
+	fs.BoolVar(&opts.yes, "yes", false, "answer yes to every confirmation prompt, for cron jobs and CI")
+	fs.BoolVar(&opts.yes, "y", false, "shorthand for --yes")
+	fs.BoolVar(&opts.failFast, "fail-fast", false, "stop at the first container that fails instead of continuing with the rest")
+	fs.BoolVar(&opts.all, "all", false, "recreate every running container")
+	fs.BoolVar(&opts.stdin, "stdin", false, "also read newline-separated container names from stdin")
+	fs.StringVar(&opts.group, "group", "", "recreate every running container labelled `LABEL=VALUE` as a unit, with one confirmation")
+	fs.DurationVar(&opts.minUptime, "min-uptime", 0, "skip containers that have been up for less than `DURATION`")
+	fs.BoolVar(&opts.trackDigest, "track-digest", false, "only recreate when the image tag resolves to a new registry digest")
+	fs.IntVar(&opts.stopTimeout, "stop-timeout", -1, "`SECONDS` docker stop waits before killing the container, overriding its own StopTimeout (default: the container's StopTimeout, else docker's 10s)")
+	fs.DurationVar(&opts.stopDeadline, "stop-deadline", 0, "abort if docker stop takes longer than `DURATION` (0 means no limit)")
+	fs.IntVar(&opts.pullConcurrency, "pull-concurrency", 0, "in batch mode, pull the images of all containers first, at most `N` at a time (0 pulls each one just before its recreate)")
+	fs.DurationVar(&opts.pullTimeout, "pull-timeout", 0, "abort a docker pull attempt that takes longer than `DURATION` (0 means no limit)")
+	fs.IntVar(&opts.pullRetries, "pull-retries", 0, "retry a failed docker pull up to `N` times")
+	fs.DurationVar(&opts.pullBudget, "pull-budget", 0, "stop retrying once the pull attempts have taken `DURATION` in total (0 means no limit)")
+	fs.DurationVar(&opts.runTimeout, "run-timeout", 0, "abort if docker run takes longer than `DURATION` (0 means no limit)")
+	fs.Var(&opts.minFreeSpace, "min-free-space", "abort before pulling if the docker root filesystem has less than `SIZE` free (e.g. 5g)")
+	fs.BoolVar(&opts.recordCommand, "record-command", false, "append each executed docker run command to ~/.drun_history")
+	fs.BoolVar(&opts.maskSecrets, "mask-secrets", false, "mask secret-looking env values in printed commands (default on when stdout is a terminal)")
+	fs.BoolVar(&opts.noColor, "no-color", false, "print messages without color codes (also set by a non-empty NO_COLOR; colors are off when output is not a terminal)")
+	fs.BoolVar(&opts.noNetworkRecreate, "no-network-recreate", false, "only attach the primary network, skipping the network connect for the others")
+	fs.BoolVar(&opts.createMissingNetworks, "create-missing-networks", false, "docker network create any of the container's networks that no longer exist")
+	fs.StringVar(&opts.backupDir, "backup-dir", "", "before stopping, save each container's docker inspect output to `DIR`/<name>-<timestamp>.json")
+	fs.BoolVar(&opts.commitBefore, "commit-before", false, "docker commit the container to <name>-snapshot:<timestamp> before stopping it")
+	fs.StringVar(&opts.preStopSignal, "pre-stop-signal", "", "send `SIGNAL` with docker kill before stopping so the app can drain")
+	fs.DurationVar(&opts.preStopWait, "pre-stop-wait", 0, "how long to wait after --pre-stop-signal before docker stop")
+	fs.DurationVar(&opts.healthTimeout, "health-timeout", 30*time.Second, "after starting, wait up to `DURATION` for the new container to become healthy and roll back otherwise (0 skips the check)")
+	fs.StringVar(&opts.smokeTest, "smoke-test", "", "after starting, run `COMMAND` in the new container and roll back if it fails")
+	fs.BoolVar(&opts.smokeTestOnHost, "smoke-test-on-host", false, "run --smoke-test on the host instead of with docker exec")
+	fs.BoolVar(&opts.verifySignature, "verify-signature", false, "verify the image signature after pulling and abort if it fails")
+	fs.StringVar(&opts.verifierCmd, "verifier-cmd", defaultVerifierCmd, "`COMMAND` used by --verify-signature; {image} is replaced by the image reference")
+	fs.BoolVar(&opts.preferRawCPU, "prefer-raw-cpu", false, "re-emit --cpu-quota/--cpu-period as-is instead of the equivalent --cpus")
+	addRuntimeFlags(fs)
+	return fs
+}
 
-	containerName := os.Args[1]
-	
-	printInfo("Processing container: %s\n", containerName)
-	
-	containerInfo, err := getContainerInfo(containerName)
+// parseFlags parses the command line of the default update flow and
+// returns the containers to handle. A non-zero code means the command line
+// was rejected and drun should exit with it.
+func parseFlags(args []string) ([]string, int) {
+	fs := newFlagSet()
+	fs.Parse(args)
+
+	set := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+
+	cfg, err := loadConfig(opts.configPath)
 	if err != nil {
-		printError("Failed to get container info: %v\n", err)
-		os.Exit(1)
+		printError("Failed to load config: %v\n", err)
+		return nil, 2
+	}
+	if cfg.SkipEnv != nil {
+		skipEnvPrefixes = cfg.SkipEnv
+	}
+	if !set["confirm-message"] {
+		opts.confirmMessage = cfg.ConfirmMessage
+	}
+
+	if !set["mask-secrets"] {
+		opts.maskSecrets = isTerminal(os.Stdout)
+	}
+	if !set["show-changes"] {
+		opts.showChanges = isTerminal(os.Stdout)
+	}
+	if opts.showSecrets {
+		opts.maskSecrets = false
 	}
 
-	imageName := containerInfo.Config.Image
-	printInfo("Container image: %s\n", imageName)
+	if opts.envFile != "" {
+		if opts.envFileEntries, err = readEnvFile(opts.envFile); err != nil {
+			printError("Failed to read env file: %v\n", err)
+			return nil, 2
+		}
+	}
+	if opts.tag != "" && !imageTag.MatchString(opts.tag) {
+		fmt.Fprintf(fs.Output(), "invalid --tag %q\n", opts.tag)
+		return nil, 2
+	}
+	switch opts.labelOrder {
+	case "sorted", "original":
+	default:
+		fmt.Fprintf(fs.Output(), "unsupported --label-order %q, expected original or sorted\n", opts.labelOrder)
+		return nil, 2
+	}
+	switch opts.output {
+	case "", "compose", "spec", "terraform", "argv":
+	default:
+		fmt.Fprintf(fs.Output(), "unsupported --output format %q\n", opts.output)
+		return nil, 2
+	}
+	if opts.json && opts.summaryJSON == "-" {
+		fmt.Fprintln(fs.Output(), "--json already prints its report on stdout; give --summary-json a file path")
+		return nil, 2
+	}
+	names := fs.Args()
+	if opts.stdin {
+		piped, err := readContainerNames(stdinReader)
+		if err != nil {
+			printError("Failed to read container names from stdin: %v\n", err)
+			return nil, 1
+		}
+		names = append(names, piped...)
+		usePromptTerminal()
+	}
 
-	if err := stopAndRemoveContainer(containerName); err != nil {
-		printError("Failed to stop/remove container: %v\n", err)
-		os.Exit(1)
+	if opts.all && opts.group != "" {
+		fmt.Fprintln(fs.Output(), "--all cannot be combined with --group")
+		return nil, 2
+	}
+	if opts.all {
+		if len(names) > 0 {
+			fmt.Fprintln(fs.Output(), "--all cannot be combined with container names")
+			return nil, 2
+		}
+		names, err := runningContainerNames()
+		if err != nil {
+			printError("Failed to list running containers: %v\n", err)
+			return nil, 1
+		}
+		return names, 0
 	}
+	if opts.group != "" {
+		if len(names) > 0 {
+			fmt.Fprintln(fs.Output(), "--group cannot be combined with container names")
+			return nil, 2
+		}
+		if !strings.Contains(opts.group, "=") {
+			fmt.Fprintln(fs.Output(), "--group expects LABEL=VALUE")
+			return nil, 2
+		}
+		names, err := runningContainerNames("label=" + opts.group)
+		if err != nil {
+			printError("Failed to list running containers: %v\n", err)
+			return nil, 1
+		}
+		if len(names) == 0 {
+			printError("No running containers carry the label %s\n", opts.group)
+			return nil, 1
+		}
+		return names, 0
+	}
+	if len(names) == 0 {
+		if opts.stdin {
+			printError("No container names on stdin\n")
+			return nil, 1
+		}
+		fs.Usage()
+		return nil, 2
+	}
+	return names, 0
+}
 
-	if err := pullLatestImage(imageName); err != nil {
-		printError("Failed to pull latest image: %v\n", err)
-		os.Exit(1)
+// readContainerNames reads newline-separated container names, as printed by
+// docker ps --format '{{.Names}}', skipping blank lines.
+func readContainerNames(r *bufio.Reader) ([]string, error) {
+	var names []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		if name := strings.TrimSpace(scanner.Text()); name != "" {
+			names = append(names, name)
+		}
 	}
+	return names, scanner.Err()
+}
 
-	runCommand := generateRunCommand(containerInfo)
-	printCommand(runCommand)
-	
-	if !confirmExecution() {
-		printWarning("Operation cancelled by user.\n")
+// usePromptTerminal moves the prompts to the controlling terminal once
+// stdin has been used up for container names. Without one, prompts fail
+// the terminal check in runUpdate and --yes is needed.
+func usePromptTerminal() {
+	tty, err := os.Open("/dev/tty")
+	if err != nil {
+		promptInput = nil
 		return
 	}
-	
-	if err := executeCommand(runCommand); err != nil {
-		printError("Failed to run container: %v\n", err)
-		os.Exit(1)
+	promptInput = tty
+	stdinReader = bufio.NewReader(tty)
+}
+
+func main() {
+	os.Exit(run(os.Args[1:]))
+}
+
+// run dispatches a command line, without the program name, to its
+// subcommand and returns the process exit code.
+func run(args []string) int {
+	// Runtime flags may come before a subcommand; the ones that talk to the
+	// daemon get them back in front of their own arguments.
+	global, rest := splitRuntimeFlags(args)
+	if len(rest) > 0 {
+		sub := slices.Concat(global, rest[1:])
+		switch rest[0] {
+		case "list":
+			return runList(sub)
+		case "completion":
+			return runCompletion(rest[1:])
+		case "__complete":
+			return runComplete(sub)
+		case "self-update":
+			return runSelfUpdate(rest[1:])
+		case "check":
+			return runCheck(sub)
+		case "diff-image":
+			return runDiffImage(sub)
+		case "config":
+			return runConfig(rest[1:])
+		case "recreate":
+			// recreate is asked for explicitly, so an unchanged image is
+			// no reason to skip it.
+			names, code := parseFlags(sub)
+			if code != 0 {
+				return code
+			}
+			opts.force = true
+			return runUpdate(names)
+		}
 	}
 
-	printSuccess("Container %s has been successfully restarted with latest image\n", containerName)
+	names, code := parseFlags(args)
+	if code != 0 {
+		return code
+	}
+	return runUpdate(names)
 }
 
-func getContainerInfo(containerName string) (*ContainerInfo, error) {
-	cmd := exec.Command("docker", "inspect", containerName)
-	output, err := cmd.Output()
-	if err != nil {
-		return nil, fmt.Errorf("failed to inspect container: %v", err)
+// runUpdate is the shared flow behind `drun` and `drun recreate`: it handles
+// every container in turn and returns the process exit code.
+func runUpdate(args []string) int {
+	opts.batch = opts.all || opts.group != "" || len(args) > 1
+
+	switch {
+	case opts.output != "":
+		messages = os.Stderr
+	case opts.json:
+		messages, commandOutput = io.Discard, os.Stderr
 	}
 
-	var containers []ContainerInfo
-	if err := json.Unmarshal(output, &containers); err != nil {
-		return nil, fmt.Errorf("failed to parse container info: %v", err)
+	switch opts.output {
+	case "compose":
+		if err := exportCompose(args); err != nil {
+			printError("Failed to generate compose file: %v\n", err)
+			return 1
+		}
+		return 0
+	case "spec":
+		if err := exportSpec(args); err != nil {
+			printError("Failed to generate run spec: %v\n", err)
+			return 1
+		}
+		return 0
+	case "terraform":
+		if err := exportTerraform(args); err != nil {
+			printError("Failed to generate terraform configuration: %v\n", err)
+			return 1
+		}
+		return 0
+	case "argv":
+		if err := exportArgv(args); err != nil {
+			printError("Failed to generate run command: %v\n", err)
+			return 1
+		}
+		return 0
 	}
 
-	if len(containers) == 0 {
-		return nil, fmt.Errorf("container not found")
+	// Without a terminal nobody can answer the prompts, and reading EOF as
+	// "no" would make a cron job cancel silently.
+	if !opts.yes && !opts.dryRun && (promptInput == nil || !isTerminal(promptInput)) {
+		printError("Confirmation required but there is no terminal to prompt on; pass --yes to run non-interactively\n")
+		return 1
 	}
 
-	return &containers[0], nil
-}
+	if opts.group != "" {
+		printInfo("Group %s:\n", opts.group)
+		for _, name := range args {
+			fmt.Fprintf(messages, "  %s\n", name)
+		}
+		fmt.Fprintln(messages)
+		if !opts.dryRun {
+			if opts.confirmMessage != "" && !opts.yes {
+				printWarning("%s\n", opts.confirmMessage)
+			}
+			if !askYesNo(fmt.Sprintf("Recreate these %d containers? (y/N): ", len(args))) {
+				printWarning("Operation cancelled by user.\n")
+				return 0
+			}
+			opts.groupConfirmed = true
+		}
+	}
 
-func stopAndRemoveContainer(containerName string) error {
-	printInfo("Stopping container %s...\n", containerName)
-	if err := exec.Command("docker", "stop", containerName).Run(); err != nil {
-		return fmt.Errorf("failed to stop container: %v", err)
+	if opts.batch && opts.pullConcurrency > 0 && !opts.dryRun && !opts.noPull {
+		opts.prePulled = prePullImages(args, opts.pullConcurrency)
 	}
 
-	printInfo("Removing container %s...\n", containerName)
-	if err := exec.Command("docker", "rm", containerName).Run(); err != nil {
-		return fmt.Errorf("failed to remove container: %v", err)
+	var results []containerResult
+	for i, containerName := range args {
+		result := containerResult{Container: containerName}
+		if err := processContainer(&result); err != nil {
+			result.Status = statusFailed
+			result.Error = err.Error()
+		}
+		results = append(results, result)
+
+		if result.Status == statusFailed && opts.failFast {
+			for _, name := range args[i+1:] {
+				results = append(results, containerResult{Container: name, Status: statusSkipped, Notes: []string{"not attempted after an earlier failure (--fail-fast)"}})
+			}
+			break
+		}
 	}
 
-	return nil
+	summary := newRunSummary(results)
+	if len(results) > 1 && !opts.json && opts.summaryJSON != "-" {
+		printSummary(summary)
+	}
+	if opts.json && !opts.dryRun {
+		if err := printJSONReport(summary); err != nil {
+			printError("Failed to write report: %v\n", err)
+			return 1
+		}
+	}
+	if opts.summaryJSON != "" {
+		if err := writeSummaryJSON(opts.summaryJSON, summary); err != nil {
+			printError("Failed to write summary: %v\n", err)
+			return 1
+		}
+	}
+	if !summary.Success {
+		return 1
+	}
+	return 0
 }
 
-func pullLatestImage(imageName string) error {
-	printInfo("Pulling latest image %s...\n", imageName)
-	cmd := exec.Command("docker", "pull", imageName)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("failed to pull image: %v", err)
+// processContainer runs the full inspect/stop/pull/regenerate/run pipeline
+// for a single container, recording the outcome in result.
+func processContainer(result *containerResult) error {
+	containerName := result.Container
+	if !opts.json {
+		printInfo("Processing container: %s\n", containerName)
 	}
-	return nil
-}
 
-func generateRunCommand(info *ContainerInfo) string {
-	var parts []string
-	parts = append(parts, "docker", "run", "-d")
+	// gone is set when an earlier run removed the container but failed
+	// before starting its replacement; the flow then resumes from the
+	// configuration saved before the removal. backup names the old
+	// container while it is kept aside during the update.
+	containerInfo, err := getContainerInfo(containerName)
+	gone := false
+	backup := ""
+	if errors.Is(err, errContainerNotFound) {
+		containerInfo, err = pendingContainerInfo(opts.stateFile, containerName)
+		gone = containerInfo != nil
+		if err == nil && !gone {
+			err = errContainerNotFound
+		}
+		if gone {
+			printWarning("Container %s no longer exists, resuming from the configuration saved before it was removed\n", containerName)
+			result.Notes = append(result.Notes, "resumed an interrupted recreate")
+		}
+		if gone && containerExists(backupName(containerName)) {
+			backup = backupName(containerName)
+		}
+	}
+	if err != nil {
+		printError("Failed to get container info: %v\n", err)
+		return err
+	}
 
-	containerName := strings.TrimPrefix(info.Name, "/")
-	parts = append(parts, "--name", containerName)
+	imageName := targetImage(containerInfo)
+	result.Image = imageName
+	result.OldImageID = containerInfo.ImageID
+	containerInfo.platform = emulatedPlatform(containerInfo)
 
-	if info.HostConfig.RestartPolicy.Name != "" {
-		parts = append(parts, "--restart", info.HostConfig.RestartPolicy.Name)
+	if opts.minUptime > 0 && containerInfo.State.Running {
+		startedAt, err := time.Parse(time.RFC3339Nano, containerInfo.State.StartedAt)
+		if err != nil {
+			printWarning("Could not parse start time of %s: %v\n", containerName, err)
+		} else if uptime := time.Since(startedAt); uptime < opts.minUptime {
+			printWarning("Skipping %s: up for %s, less than --min-uptime %s\n", containerName, uptime.Round(time.Second), opts.minUptime)
+			result.Status = statusSkipped
+			return nil
+		}
 	}
 
-	for _, bind := range info.HostConfig.Binds {
-		parts = append(parts, "-v", bind)
+	if opts.strict {
+		fields, err := unmodeledFields(containerInfo)
+		if err != nil {
+			printError("Strict check failed: %v\n", err)
+			return err
+		}
+		if len(fields) > 0 {
+			err := fmt.Errorf("container uses fields drun does not preserve: %s", strings.Join(fields, ", "))
+			printError("Strict check failed: %v\n", err)
+			return err
+		}
 	}
 
-	for port, bindings := range info.HostConfig.PortBindings {
-		for _, binding := range bindings {
-			if binding.HostPort != "" {
-				hostPort := binding.HostPort
-				parts = append(parts, "-p", fmt.Sprintf("%s:%s", hostPort, port))
+	var digest string
+	if opts.trackDigest {
+		var changed bool
+		digest, changed, err = digestChanged(containerInfo, imageName)
+		if err != nil {
+			printError("Failed to check image digest: %v\n", err)
+			return err
+		}
+		if !changed {
+			printInfo("Skipping %s: %s still resolves to %s\n", containerName, imageName, digest)
+			result.Status = statusSkipped
+			return nil
+		}
+	}
+
+	if opts.verboseInspect {
+		if err := printParsedInfo(containerInfo); err != nil {
+			printWarning("Could not print container info: %v\n", err)
+		}
+	}
+
+	if opts.dryRun {
+		p := newPlan(containerInfo, gone)
+		if p.Dropped, err = unmodeledFields(containerInfo); err != nil {
+			printWarning("Could not check for unsupported fields: %v\n", err)
+		}
+		if err := printPlan(p); err != nil {
+			printError("Failed to print plan: %v\n", err)
+			return err
+		}
+		result.Status = statusPlanned
+		return nil
+	}
+
+	printInfo("Container image: %s\n", containerInfo.Config.Image)
+	if imageName != containerInfo.Config.Image {
+		printInfo("Using image: %s\n", imageName)
+	}
+
+	// The stale cidfile has to go before docker run, and on a remote daemon
+	// it lives on a filesystem drun cannot reach.
+	if path := containerInfo.HostConfig.ContainerIDFile; path != "" {
+		if host := remoteDaemon(); host != "" {
+			err := fmt.Errorf("container %s writes its ID to %s on %s, which drun cannot remove from here; run drun on that host instead", containerName, path, host)
+			printError("%v\n", err)
+			return err
+		}
+	}
+
+	running := !gone && (opts.assumeRunning || containerInfo.State.Running)
+	if !running && !gone {
+		printWarning("Container %s is not running (status: %s)\n", containerName, containerInfo.State.Status)
+		if !askYesNo("Recreate it from its stored config without stopping it? (y/N): ") {
+			printWarning("Operation cancelled by user.\n")
+			result.Status = statusCancelled
+			return nil
+		}
+	}
+
+	if opts.noPull {
+		printInfo("Skipping image pull, using the local image %s\n", imageName)
+	} else if opts.prePulled[prePullKey(imageName, containerInfo.platform)] {
+		printInfo("Image %s was already pulled for this batch\n", imageName)
+	} else {
+		if opts.minFreeSpace > 0 {
+			if err := checkFreeSpace(int64(opts.minFreeSpace)); err != nil {
+				printError("Pre-pull check failed: %v\n", err)
+				return err
 			}
 		}
+		if err := pullLatestImage(imageName, containerInfo.platform); err != nil {
+			printError("Failed to pull latest image: %v\n", err)
+			return err
+		}
 	}
 
-	for _, env := range info.Config.Env {
-		if !shouldSkipEnv(env) {
-			parts = append(parts, "-e", env)
+	// The image is pulled while the old container is still serving, so an
+	// unchanged image costs no downtime at all.
+	newImageID, err := localImageID(imageName)
+	if err != nil {
+		printWarning("Could not resolve pulled image ID: %v\n", err)
+	}
+	result.NewImageID = newImageID
+
+	if opts.verifySignature {
+		pinned, err := pinnedReference(imageName, newImageID)
+		if err != nil {
+			printError("Signature verification failed, not starting the new container: %v\n", err)
+			return err
 		}
+		printInfo("Verifying signature of %s...\n", pinned)
+		if err := verifySignature(pinned); err != nil {
+			printError("Signature verification failed, not starting the new container: %v\n", err)
+			return err
+		}
+		printSuccess("Signature of %s verified\n", pinned)
+	}
+	// A --tag or --registry-mirror rewrite is recreated even when both
+	// references resolve to the same image: the container has to run from
+	// the new reference.
+	if !gone && !opts.force && !opts.noPull && newImageID != "" && newImageID == containerInfo.ImageID && imageName == containerInfo.Config.Image {
+		printSuccess("Container %s already runs the latest %s, nothing to do (use --force to recreate anyway)\n", containerName, imageName)
+		result.Status = statusSkipped
+		result.Notes = append(result.Notes, "image unchanged")
+		return nil
 	}
 
-	if info.HostConfig.Privileged {
-		parts = append(parts, "--privileged")
+	runArgs := generateRunCommand(containerInfo)
+	printCommand(displayCommand(runArgs))
+	result.Argv = runArgs
+	if opts.maskSecrets {
+		result.Argv = maskCommandSecrets(runArgs)
 	}
 
-	if info.HostConfig.PublishAllPorts {
-		parts = append(parts, "-P")
+	var changes []string
+	if opts.showChanges {
+		state, err := loadState(opts.stateFile)
+		if err != nil {
+			printWarning("Could not read state file: %v\n", err)
+		}
+		var previous *containerState
+		if entry, ok := state[containerName]; ok && entry.Argv != nil {
+			if opts.maskSecrets {
+				entry.Argv = maskCommandSecrets(entry.Argv)
+			}
+			previous = &entry
+		}
+		// result.Argv is masked the same way, so a secret shows up in the
+		// summary as a changed flag without its values.
+		changes = describeChanges(containerInfo.Config.Image, containerInfo.ImageID, imageName, newImageID, previous, result.Argv)
 	}
 
-	if info.HostConfig.NetworkMode != "" && info.HostConfig.NetworkMode != "default" {
-		parts = append(parts, "--network", info.HostConfig.NetworkMode)
+	// Nothing has been touched yet, so the service keeps running while the
+	// operator reads the prompt and a cancel leaves it as it was.
+	if !confirmExecution(changes) {
+		printWarning("Operation cancelled by user.\n")
+		result.Status = statusCancelled
+		return nil
 	}
 
-	parts = append(parts, info.Config.Image)
+	if opts.commitBefore && !gone {
+		snapshot, err := commitSnapshot(containerName)
+		if err != nil {
+			printError("Failed to snapshot container: %v\n", err)
+			return err
+		}
+		printSuccess("Committed container state to %s\n", snapshot)
+		result.Notes = append(result.Notes, "snapshot committed to "+snapshot)
+	}
 
-	if len(info.Config.Cmd) > 0 {
-		parts = append(parts, info.Config.Cmd...)
+	if opts.backupDir != "" && !gone {
+		path, err := writeInspectBackup(opts.backupDir, containerName, containerInfo.raw, time.Now())
+		if err != nil {
+			printError("%v\n", err)
+			return err
+		}
+		printInfo("Saved the inspect output of %s to %s\n", containerName, path)
+		result.Notes = append(result.Notes, "inspect output saved to "+path)
+	}
+
+	if !gone {
+		// Saved first so that if drun itself dies before the new container
+		// is up, the next run can pick up from the same configuration.
+		if err := recordPending(opts.stateFile, containerName, containerInfo.raw); err != nil {
+			printWarning("Could not save container config for retries: %v\n", err)
+		}
+		forgetContainerInfo(containerName)
+		grace := stopGracePeriod(containerInfo)
+		if own := containerInfo.Config.StopTimeout; own != nil && *own != grace {
+			printInfo("Using --stop-timeout %ds instead of the container's own StopTimeout of %ds\n", grace, *own)
+		}
+		if err := stopContainer(containerName, running, grace); err != nil {
+			printError("Failed to stop container: %v\n", err)
+			keepOldContainer(containerName)
+			return err
+		}
+		if backup, err = backupContainer(containerName); err != nil {
+			printError("Failed to back up container: %v\n", err)
+			if running {
+				if err := dockerCommand("start", containerName).Run(); err != nil {
+					printWarning("Could not restart %s: %v\n", containerName, err)
+				}
+			}
+			keepOldContainer(containerName)
+			return err
+		}
+	}
+
+	// Until the new container is up, any way out of this function puts the
+	// old one back under its name.
+	updated, cidfile := false, ""
+	defer func() {
+		if backup == "" || updated {
+			return
+		}
+		if err := restoreBackup(containerName, backup, opts.assumeRunning || containerInfo.State.Running); err != nil {
+			printError("Failed to restore %s: %v\n", containerName, err)
+			result.Notes = append(result.Notes, "restoring the old container failed, it is kept as "+backup)
+			return
+		}
+		printSuccess("Restored the previous container %s\n", containerName)
+		result.Notes = append(result.Notes, "previous container restored")
+		if cidfile != "" {
+			if err := os.WriteFile(cidfile, []byte(containerInfo.ID), 0o644); err != nil {
+				printWarning("Could not write %s back for the restored container: %v\n", cidfile, err)
+			}
+		}
+		keepOldContainer(containerName)
+	}()
+
+	if labels := runLabels(containerInfo); opts.labelFile != "" && len(labels) > 0 {
+		path := labelFilePath(containerInfo)
+		if err := writeLabelFile(path, labelKeys(containerInfo, labels), labels); err != nil {
+			printError("Failed to write label file: %v\n", err)
+			return err
+		}
+		printInfo("Wrote %d labels to %s\n", len(labels), path)
+	}
+
+	// docker run refuses to overwrite an existing cidfile, and the one left
+	// behind by the old container is stale by now. A restore writes it again.
+	if path := containerInfo.HostConfig.ContainerIDFile; path != "" {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			printError("Failed to remove stale cidfile %s: %v\n", path, err)
+			return err
+		}
+		cidfile = path
+	}
+
+	if err := ensurePrimaryNetwork(containerInfo); err != nil {
+		printError("%v\n", err)
+		return err
+	}
+
+	// An attached container has already exited by the time docker run
+	// returns, so there is nothing left to wait for.
+	verifyStart := opts.healthTimeout > 0 && !(isInteractive(containerInfo) && !opts.batch)
+
+	// While the container is being verified it gets no restart policy, so a
+	// crash loop fails the checks instead of hiding behind restarts; the real
+	// one is applied with docker update once they passed.
+	execArgs, restartPolicy := runArgs, ""
+	if opts.smokeTest != "" || verifyStart {
+		execArgs, restartPolicy = withoutRestartPolicy(runArgs)
+	}
+
+	if err := executeRunCommand(execArgs); err != nil {
+		printError("Failed to run container: %v\n", err)
+		return err
+	}
+
+	if networks := extraNetworks(containerInfo); len(networks) > 0 && !opts.noNetworkRecreate {
+		result.Notes = append(result.Notes, connectNetworks(containerInfo, networks)...)
+	}
+
+	if verifyStart {
+		printInfo("Waiting for %s to come up...\n", containerName)
+		state, err := waitForContainer(containerName, opts.healthTimeout)
+		if err != nil {
+			printError("%v\n", err)
+			return err
+		}
+		printSuccess("Container %s is %s\n", containerName, state)
+	}
+
+	if opts.smokeTest != "" {
+		printInfo("Running smoke test for %s...\n", containerName)
+		if err := runSmokeTest(containerName); err != nil {
+			printError("%v\n", err)
+			return err
+		}
+		printSuccess("Smoke test passed\n")
+	}
+
+	if restartPolicy != "" {
+		if err := applyRestartPolicy(containerName, restartPolicy); err != nil {
+			printError("%v\n", err)
+			return err
+		}
+		printInfo("Applied restart policy %s\n", restartPolicy)
+	}
+
+	updated = true
+	if backup != "" {
+		if err := removeBackup(backup); err != nil {
+			printWarning("Could not remove the previous container: %v\n", err)
+			result.Notes = append(result.Notes, "previous container kept as "+backup)
+		}
+	}
+
+	if opts.recordCommand {
+		if err := appendHistory(defaultHistoryPath(), displayCommand(runArgs)); err != nil {
+			printWarning("Could not record command in history: %v\n", err)
+		}
+	}
+
+	entry := containerState{Image: imageName, ImageID: newImageID, Digest: digest, Argv: runArgs, UpdatedAt: time.Now().UTC()}
+	if err := recordState(opts.stateFile, containerName, entry); err != nil {
+		printWarning("Could not update state file: %v\n", err)
 	}
 
-	return strings.Join(parts, " ")
+	if opts.noPull {
+		printSuccess("Container %s has been successfully recreated\n", containerName)
+	} else {
+		printSuccess("Container %s has been successfully restarted with latest image\n", containerName)
+	}
+	result.Status = statusRestarted
+
+	if count := containerInfo.RestartCount; count > 0 {
+		note := fmt.Sprintf("restart count reset to 0 (old container had restarted %d times)", count)
+		if policy := containerInfo.HostConfig.RestartPolicy; policy.Name == "on-failure" && policy.MaximumRetryCount > 0 {
+			note += fmt.Sprintf("; on-failure:%d starts with a fresh retry budget", policy.MaximumRetryCount)
+		}
+		printInfo("Note: %s\n", note)
+		result.Notes = append(result.Notes, note)
+	}
+	return nil
 }
 
-func shouldSkipEnv(env string) bool {
-	skipPatterns := []string{
-		"PATH=",
-		"HOSTNAME=",
-		"HOME=",
-		"TERM=",
+// keepOldContainer forgets the configuration saved for resuming once an
+// update ends with the old container still in place under its name.
+func keepOldContainer(containerName string) {
+	if err := clearPending(opts.stateFile, containerName); err != nil {
+		printWarning("Could not update state file: %v\n", err)
 	}
+}
 
-	for _, pattern := range skipPatterns {
-		if strings.HasPrefix(env, pattern) {
-			return true
+// printParsedInfo dumps the subset of the inspect output drun understands,
+// which shows at a glance which fields came back empty.
+func printParsedInfo(info *ContainerInfo) error {
+	dump := *info
+	if !opts.showSecrets {
+		dump.Config.Env = make([]string, len(info.Config.Env))
+		for i, env := range info.Config.Env {
+			dump.Config.Env[i] = redactEnv(env)
 		}
 	}
 
-	return false
+	data, err := json.MarshalIndent(dump, "", "  ")
+	if err != nil {
+		return err
+	}
+	printInfo("Parsed container info:\n")
+	fmt.Fprintf(messages, "%s\n\n", data)
+	return nil
 }
 
-func confirmExecution() bool {
-	reader := bufio.NewReader(os.Stdin)
-	printPrompt("Do you want to execute this command? (y/N): ")
-	
-	response, err := reader.ReadString('\n')
+// errContainerNotFound is returned by getContainerInfo when the runtime has
+// no container by that name.
+var errContainerNotFound = errors.New("container not found")
+
+// inspectCache holds the inspect output of containers already inspected in
+// this run, so the pre-pull phase and the recreate do not both ask the daemon.
+// Entries are dropped with forgetContainerInfo whenever drun changes the
+// container.
+var inspectCache = make(map[string]json.RawMessage)
+
+func getContainerInfo(containerName string) (*ContainerInfo, error) {
+	// Each call parses afresh, callers are free to modify what they get.
+	if raw, ok := inspectCache[containerName]; ok && opts.inspectCache {
+		return parseContainerInfo(raw)
+	}
+
+	cmd := dockerCommand("inspect", containerName)
+	output, err := cmd.Output()
 	if err != nil {
-		return false
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && strings.Contains(strings.ToLower(string(exitErr.Stderr)), "no such") {
+			return nil, errContainerNotFound
+		}
+		return nil, fmt.Errorf("failed to inspect container: %v", err)
 	}
-	
-	response = strings.TrimSpace(strings.ToLower(response))
-	return response == "y" || response == "yes"
+
+	var containers []json.RawMessage
+	if err := json.Unmarshal(output, &containers); err != nil {
+		return nil, fmt.Errorf("failed to parse container info: %v", err)
+	}
+
+	if len(containers) == 0 {
+		return nil, errContainerNotFound
+	}
+	if opts.inspectCache {
+		inspectCache[containerName] = containers[0]
+	}
+	return parseContainerInfo(containers[0])
 }
 
-func executeCommand(command string) error {
-	cmd := exec.Command("sh", "-c", command)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	return cmd.Run()
-}
\ No newline at end of file
+// forgetContainerInfo drops a container's cached inspect output after it was
+// stopped, renamed or replaced.
+func forgetContainerInfo(containerName string) {
+	delete(inspectCache, containerName)
+}
+
+// parseContainerInfo decodes a single inspect object, keeping the raw JSON.
+func parseContainerInfo(raw json.RawMessage) (*ContainerInfo, error) {
+	info := &ContainerInfo{raw: raw}
+	if err := json.Unmarshal(raw, info); err != nil {
+		return nil, fmt.Errorf("failed to parse container info: %v", err)
+	}
+	return info, nil
+}
+
+// stopGracePeriod is the container's stop grace period: an explicit
+// --stop-timeout, then the container's own StopTimeout (docker run
+// --stop-timeout), and -1 to leave docker's default of 10 seconds. It is
+// both the docker stop -t value and what the new container's --stop-timeout
+// is set to.
+func stopGracePeriod(info *ContainerInfo) int {
+	if opts.stopTimeout >= 0 {
+		return opts.stopTimeout
+	}
+	if info.Config.StopTimeout != nil {
+		return *info.Config.StopTimeout
+	}
+	return -1
+}
+
+// snapshotRef names the image --commit-before saves a container to. Image
+// repositories must be lowercase while container names need not be.
+func snapshotRef(containerName string, at time.Time) string {
+	return fmt.Sprintf("%s-snapshot:%s", strings.ToLower(containerName), at.UTC().Format("20060102-150405"))
+}
+
+// commitSnapshot saves the container's filesystem as an image so in-container
+// state can be recovered after the recreate.
+func commitSnapshot(containerName string) (string, error) {
+	ref := snapshotRef(containerName, time.Now())
+	printInfo("Committing container %s to %s...\n", containerName, ref)
+	if err := dockerCommand("commit", containerName, ref).Run(); err != nil {
+		return "", fmt.Errorf("failed to commit container: %v", err)
+	}
+	return ref, nil
+}
+
+// stopContainer stops the container when stop is set, sending
+// --pre-stop-signal first. Stopped containers are left alone since
+// `docker stop` on them is pointless. grace is passed as docker stop -t;
+// a negative value leaves docker's own default.
+func stopContainer(containerName string, stop bool, grace int) error {
+	if stop && opts.preStopSignal != "" {
+		printInfo("Sending %s to container %s...\n", opts.preStopSignal, containerName)
+		if err := dockerCommand("kill", "--signal", opts.preStopSignal, containerName).Run(); err != nil {
+			return fmt.Errorf("failed to signal container: %v", err)
+		}
+		if opts.preStopWait > 0 {
+			printInfo("Waiting %s before stopping...\n", opts.preStopWait)
+			time.Sleep(opts.preStopWait)
+		}
+	}
+
+	if stop {
+		printInfo("Stopping container %s...\n", containerName)
+		args := []string{"stop"}
+		if grace >= 0 {
+			args = append(args, "-t", strconv.Itoa(grace))
+		}
+		args = append(args, containerName)
+
+		ctx, cancel := stepContext(opts.stopDeadline)
+		defer cancel()
+		err := dockerCommandContext(ctx, args...).Run()
+		if err := stepError(ctx, "docker stop", opts.stopDeadline, err); err != nil {
+			return fmt.Errorf("failed to stop container: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// checkFreeSpace fails when the filesystem holding docker's data root has
+// less than minFree bytes available, so a large pull cannot fill the disk
+// halfway through an update. The root is stat'ed locally, so a daemon whose
+// data root is not visible from here only produces a warning.
+func checkFreeSpace(minFree int64) error {
+	// The docker root dir of a remote daemon is not on this machine.
+	if host := remoteDaemon(); host != "" {
+		printWarning("Skipping free space check, %s is not a local daemon\n", host)
+		return nil
+	}
+	output, err := dockerCommand("info", "--format", "{{.DockerRootDir}}").Output()
+	if err != nil {
+		return fmt.Errorf("failed to query docker root dir: %v", err)
+	}
+	rootDir := strings.TrimSpace(string(output))
+
+	free, err := freeDiskSpace(rootDir)
+	if err != nil {
+		printWarning("Skipping free space check for %s: %v\n", rootDir, err)
+		return nil
+	}
+	if free < uint64(minFree) {
+		return fmt.Errorf("only %d bytes free in %s, below --min-free-space of %d bytes", free, rootDir, minFree)
+	}
+	return nil
+}
+
+func pullLatestImage(imageName, platform string) error {
+	printInfo("Pulling latest image %s...\n", imageName)
+	return pullImage(imageName, platform, commandOutput, os.Stderr)
+}
+
+// pullRetryDelay is the wait before the first pull retry; it doubles with
+// every further attempt up to maxPullRetryDelay.
+const (
+	pullRetryDelay    = 2 * time.Second
+	maxPullRetryDelay = 30 * time.Second
+)
+
+// pullImage runs docker pull with the given output streams, so that
+// concurrent pulls can buffer theirs instead of interleaving on the terminal.
+// A failed pull is retried up to --pull-retries times as long as the total
+// time stays within --pull-budget; each attempt is further capped by
+// --pull-timeout. The error says which of the limits ended the retries.
+func pullImage(imageName, platform string, stdout, stderr io.Writer) error {
+	args := []string{"pull"}
+	if platform != "" {
+		args = append(args, "--platform", platform)
+	}
+	args = append(args, imageName)
+
+	start := time.Now()
+	for attempt := 1; ; attempt++ {
+		timeout := opts.pullTimeout
+		if opts.pullBudget > 0 {
+			if remaining := opts.pullBudget - time.Since(start); timeout <= 0 || remaining < timeout {
+				timeout = remaining
+			}
+		}
+
+		ctx, cancel := stepContext(timeout)
+		cmd := dockerCommandContext(ctx, args...)
+		cmd.Stdout = stdout
+		cmd.Stderr = stderr
+		err := stepError(ctx, "docker pull", timeout, cmd.Run())
+		cancel()
+		if err == nil {
+			return nil
+		}
+
+		if attempt > opts.pullRetries {
+			if opts.pullRetries > 0 {
+				return fmt.Errorf("failed to pull image, giving up after %d attempts (--pull-retries): %v", attempt, err)
+			}
+			return fmt.Errorf("failed to pull image: %v", err)
+		}
+		delay := min(pullRetryDelay<<(attempt-1), maxPullRetryDelay)
+		if opts.pullBudget > 0 && time.Since(start)+delay >= opts.pullBudget {
+			return fmt.Errorf("failed to pull image, --pull-budget %s runs out before another attempt after %d attempts: %v", opts.pullBudget, attempt, err)
+		}
+		printWarning("Pull of %s failed (attempt %d of %d), retrying in %s: %v\n", imageName, attempt, opts.pullRetries+1, delay, err)
+		time.Sleep(delay)
+	}
+}
+
+// plan describes the actions drun would take for a container. It is what
+// --dry-run prints, either as text or as JSON with --json.
+type plan struct {
+	Container string       `json:"container"`
+	Image     string       `json:"image"`
+	Actions   []planAction `json:"actions"`
+	// Rollback lists what restores the previous container if a step after
+	// the rename fails.
+	Rollback []planAction `json:"rollback,omitempty"`
+	// Dropped lists the config fields drun does not model and a real
+	// recreate would lose, the same ones --strict refuses.
+	Dropped []string `json:"dropped,omitempty"`
+}
+
+type planAction struct {
+	Action string   `json:"action"`
+	Target string   `json:"target,omitempty"`
+	Argv   []string `json:"argv,omitempty"`
+	Signal string   `json:"signal,omitempty"`
+	// NewName is the name a rename action moves Target to.
+	NewName string `json:"new_name,omitempty"`
+}
+
+func newPlan(info *ContainerInfo, gone bool) *plan {
+	containerName := strings.TrimPrefix(info.Name, "/")
+	p := &plan{Container: containerName, Image: targetImage(info)}
+	if !opts.noPull {
+		p.Actions = append(p.Actions, planAction{Action: "pull", Target: targetImage(info)})
+	}
+	if opts.verifySignature {
+		p.Actions = append(p.Actions, planAction{Action: "verify", Target: targetImage(info)})
+	}
+	if opts.commitBefore && !gone {
+		p.Actions = append(p.Actions, planAction{Action: "commit", Target: snapshotRef(containerName, time.Now())})
+	}
+	if !gone && (opts.assumeRunning || info.State.Running) {
+		if opts.preStopSignal != "" {
+			p.Actions = append(p.Actions, planAction{Action: "kill", Target: containerName, Signal: opts.preStopSignal})
+		}
+		p.Actions = append(p.Actions, planAction{Action: "stop", Target: containerName})
+	}
+	if !gone {
+		p.Actions = append(p.Actions, planAction{Action: "rename", Target: containerName, NewName: backupName(containerName)})
+	}
+	// The plan is only ever shown, in the text or --json form, so it carries
+	// the masked argv.
+	runArgs := buildRunArgs(info)
+	if opts.maskSecrets {
+		runArgs = maskCommandSecrets(runArgs)
+	}
+	p.Actions = append(p.Actions, planAction{Action: "run", Target: containerName, Argv: runArgs})
+	if !opts.noNetworkRecreate {
+		for _, name := range extraNetworks(info) {
+			p.Actions = append(p.Actions, planAction{Action: "network connect", Target: name})
+		}
+	}
+	verifyStart := opts.healthTimeout > 0 && !(isInteractive(info) && !opts.batch)
+	if verifyStart {
+		p.Actions = append(p.Actions, planAction{Action: "wait", Target: containerName})
+	}
+	if opts.smokeTest != "" {
+		p.Actions = append(p.Actions, planAction{Action: "smoke-test", Target: containerName})
+	}
+	if (verifyStart || opts.smokeTest != "") && info.HostConfig.RestartPolicy.Name != "" && info.HostConfig.RestartPolicy.Name != "no" {
+		p.Actions = append(p.Actions, planAction{Action: "update --restart", Target: containerName})
+	}
+	if backup := backupName(containerName); !gone || containerExists(backup) {
+		p.Actions = append(p.Actions, planAction{Action: "rm", Target: backup})
+		p.Rollback = []planAction{
+			{Action: "rm -f", Target: containerName},
+			{Action: "rename", Target: backup, NewName: containerName},
+		}
+		if opts.assumeRunning || info.State.Running {
+			p.Rollback = append(p.Rollback, planAction{Action: "start", Target: containerName})
+		}
+	}
+	return p
+}
+
+func (a planAction) String() string {
+	switch {
+	case a.Signal != "":
+		return fmt.Sprintf("%s %s (%s)", a.Action, a.Target, a.Signal)
+	case a.NewName != "":
+		return fmt.Sprintf("%s %s -> %s", a.Action, a.Target, a.NewName)
+	}
+	return a.Action + " " + a.Target
+}
+
+func printPlan(p *plan) error {
+	if opts.json {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(p)
+	}
+
+	printInfo("Dry run, nothing will be changed. Planned actions:\n")
+	var runArgs []string
+	for i, action := range p.Actions {
+		fmt.Fprintf(messages, "  %d. %s\n", i+1, action)
+		if action.Argv != nil {
+			runArgs = action.Argv
+		}
+	}
+	if len(p.Rollback) > 0 {
+		fmt.Fprintf(messages, "  If a later step fails, the previous container is restored:\n")
+		for i, action := range p.Rollback {
+			fmt.Fprintf(messages, "    %d. %s\n", i+1, action)
+		}
+	}
+	fmt.Fprintln(messages)
+	if len(p.Dropped) > 0 {
+		printWarning("These fields are not preserved and would be dropped:\n")
+		for _, field := range p.Dropped {
+			fmt.Fprintf(messages, "  %s\n", field)
+		}
+		fmt.Fprintln(messages)
+	}
+	printCommand(displayCommand(runArgs))
+	return nil
+}
+
+// generateRunCommand returns the argv that recreates the container. It is
+// executed as-is, without a shell; formatCommand renders it for display.
+func generateRunCommand(info *ContainerInfo) []string {
+	return buildRunArgs(info)
+}
+
+// formatCommand renders an argv as a shell command line, quoting arguments
+// that contain spaces or shell metacharacters (e.g. a Cmd of "daemon off;").
+func formatCommand(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = shellQuote(arg)
+	}
+	return strings.Join(quoted, " ")
+}
+
+// displayCommand is the command line shown to the user. It only differs
+// from what is executed when --mask-secrets hides credential values.
+func displayCommand(args []string) string {
+	if opts.maskSecrets {
+		args = maskCommandSecrets(args)
+	}
+	return formatCommand(args)
+}
+
+var shellSafe = regexp.MustCompile(`^[A-Za-z0-9_@%+=:,./-]+$`)
+
+func shellQuote(arg string) string {
+	if shellSafe.MatchString(arg) {
+		return arg
+	}
+	return "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+}
+
+// buildRunArgs reconstructs the docker run argv for a container from its
+// inspect output.
+func buildRunArgs(info *ContainerInfo) []string {
+	var parts []string
+	if isInteractive(info) && opts.batch {
+		printWarning("Container %s is interactive; dropping -it since batch runs cannot attach a terminal\n", strings.TrimPrefix(info.Name, "/"))
+		parts = append(parts, runtimeCommand("run", "-d")...)
+	} else if isInteractive(info) {
+		parts = append(parts, runtimeCommand("run", "-it")...)
+	} else {
+		parts = append(parts, runtimeCommand("run", "-d")...)
+		if info.Config.OpenStdin {
+			parts = append(parts, "-i")
+		}
+		if info.Config.Tty {
+			parts = append(parts, "-t")
+		}
+	}
+
+	containerName := strings.TrimPrefix(info.Name, "/")
+	parts = append(parts, "--name", containerName)
+
+	if info.platform != "" {
+		parts = append(parts, "--platform", info.platform)
+	}
+
+	if restart := restartPolicyFlag(info.HostConfig.RestartPolicy); restart != "" {
+		parts = append(parts, "--restart", restart)
+	}
+
+	parts = append(parts, resourceArgs(info)...)
+
+	if info.HostConfig.OomScoreAdj != 0 {
+		parts = append(parts, "--oom-score-adj", fmt.Sprintf("%d", info.HostConfig.OomScoreAdj))
+	}
+	if grace := stopGracePeriod(info); grace >= 0 {
+		parts = append(parts, "--stop-timeout", strconv.Itoa(grace))
+	}
+
+	for _, volume := range volumeFlags(info) {
+		parts = append(parts, "-v", volume)
+	}
+
+	for _, source := range info.HostConfig.VolumesFrom {
+		parts = append(parts, "--volumes-from", volumesFromFlag(source))
+	}
+
+	for _, link := range info.HostConfig.Links {
+		name, alias := parseLink(link)
+		parts = append(parts, "--link", name+":"+alias)
+	}
+
+	// Entries are passed through verbatim: the special "host-gateway" value
+	// must reach the daemon unresolved so it maps to the new host gateway IP.
+	for _, host := range info.HostConfig.ExtraHosts {
+		parts = append(parts, "--add-host", host)
+	}
+
+	// Search domains go through unfiltered: "." is how --dns-search turns
+	// the host's search list off, not an empty entry.
+	for _, server := range info.HostConfig.Dns {
+		parts = append(parts, "--dns", server)
+	}
+	for _, domain := range info.HostConfig.DnsSearch {
+		parts = append(parts, "--dns-search", domain)
+	}
+	for _, option := range info.HostConfig.DnsOptions {
+		parts = append(parts, "--dns-option", option)
+	}
+
+	// --read-only goes right before the tmpfs mounts that usually provide
+	// its writable paths, so the pair reads together in the command.
+	if info.HostConfig.ReadonlyRootfs {
+		if !hasWritableTmpfs(info.HostConfig.Tmpfs) {
+			printWarning("Container %s has a read-only root filesystem but no writable tmpfs mount; apps that write to /tmp or /run will fail\n", strings.TrimPrefix(info.Name, "/"))
+		}
+		parts = append(parts, "--read-only")
+	}
+
+	for _, path := range sortedKeys(info.HostConfig.Tmpfs) {
+		parts = append(parts, "--tmpfs", tmpfsFlag(path, info.HostConfig.Tmpfs[path]))
+	}
+
+	for _, key := range sortedKeys(info.HostConfig.Sysctls) {
+		parts = append(parts, "--sysctl", sysctlFlag(key, info.HostConfig.Sysctls[key], info.HostConfig.NetworkMode))
+	}
+
+	for _, port := range sortedPorts(info.HostConfig.PortBindings) {
+		for _, binding := range info.HostConfig.PortBindings[port] {
+			if binding.HostPort != "" {
+				parts = append(parts, "-p", portFlag(binding, port))
+			}
+		}
+	}
+
+	// Variables from --env-file stay in the file, which keeps their values
+	// out of the printed command; the file goes first so explicit -e values
+	// still override it as they did originally.
+	if opts.envFile != "" {
+		parts = append(parts, "--env-file", opts.envFile)
+	}
+
+	// Unlike labels and sysctls, env vars are kept in their original order
+	// rather than sorted: with duplicate names the last one wins, and some
+	// apps read them in order.
+	for _, env := range info.Config.Env {
+		if !shouldSkipEnv(env) && !opts.envFileEntries.covers(env) {
+			parts = append(parts, "-e", env)
+		}
+	}
+
+	// The label file is only written for a recreate that runs, so dry runs
+	// and --output keep one flag per label rather than name a missing file.
+	labels := runLabels(info)
+	switch {
+	case len(labels) == 0:
+	case opts.labelFile != "" && !opts.dryRun && opts.output == "":
+		parts = append(parts, "--label-file", labelFilePath(info))
+	default:
+		for _, key := range labelKeys(info, labels) {
+			parts = append(parts, "-l", key+"="+labels[key])
+		}
+	}
+
+	// Once ALL is dropped (or added) every entry on the other side matters,
+	// so the default set is only consulted for the common incremental case.
+	defaultCaps := capabilitySet(strings.Split(opts.defaultCaps, ","))
+	addsAll := capabilitySet(info.HostConfig.CapAdd)["ALL"]
+	dropsAll := capabilitySet(info.HostConfig.CapDrop)["ALL"]
+	for _, capability := range info.HostConfig.CapAdd {
+		if dropsAll || !defaultCaps[normalizeCapability(capability)] {
+			parts = append(parts, "--cap-add", capability)
+		}
+	}
+	for _, capability := range info.HostConfig.CapDrop {
+		if addsAll || dropsAll || defaultCaps[normalizeCapability(capability)] {
+			parts = append(parts, "--cap-drop", capability)
+		}
+	}
+
+	for _, ulimit := range info.HostConfig.Ulimits {
+		parts = append(parts, "--ulimit", fmt.Sprintf("%s=%d:%d", ulimit.Name, ulimit.Soft, ulimit.Hard))
+	}
+
+	// Annotations are passed through untouched, including the io.kubernetes.*
+	// ones CRI tooling relies on; unlike labels none come from the image.
+	for _, key := range sortedKeys(info.HostConfig.Annotations) {
+		parts = append(parts, "--annotation", key+"="+info.HostConfig.Annotations[key])
+	}
+
+	// keep-groups is podman's way of passing the caller's supplementary
+	// groups through; docker would look it up as a group name and fail.
+	for _, group := range info.HostConfig.GroupAdd {
+		if group == "keep-groups" && !isPodman() {
+			printWarning("Dropping --group-add keep-groups, which only podman supports\n")
+			continue
+		}
+		parts = append(parts, "--group-add", group)
+	}
+
+	for _, device := range info.HostConfig.Devices {
+		parts = append(parts, "--device", deviceFlag(device))
+	}
+
+	for _, rule := range info.HostConfig.DeviceCgroupRules {
+		parts = append(parts, "--device-cgroup-rule", rule)
+	}
+
+	parts = append(parts, healthcheckArgs(overriddenHealthcheck(info))...)
+
+	parts = append(parts, logArgs(info.HostConfig.LogConfig)...)
+
+	if info.HostConfig.ContainerIDFile != "" {
+		parts = append(parts, "--cidfile", info.HostConfig.ContainerIDFile)
+	}
+
+	if info.HostConfig.Privileged {
+		parts = append(parts, "--privileged")
+	}
+
+	if info.HostConfig.PublishAllPorts {
+		parts = append(parts, "-P")
+	}
+
+	if info.HostConfig.NetworkMode != "" && info.HostConfig.NetworkMode != "default" {
+		parts = append(parts, "--network", info.HostConfig.NetworkMode)
+		for _, alias := range networkAliases(info, info.HostConfig.NetworkMode) {
+			parts = append(parts, "--network-alias", alias)
+		}
+	}
+
+	parts = append(parts, imageOverrideArgs(info)...)
+
+	entrypointFlags, entrypointCmd := entrypointArgs(info)
+	parts = append(parts, entrypointFlags...)
+
+	parts = append(parts, targetImage(info))
+	parts = append(parts, entrypointCmd...)
+
+	if len(info.Config.Cmd) > 0 {
+		parts = append(parts, info.Config.Cmd...)
+	}
+
+	return parts
+}
+
+// isInteractive reports whether the container was started attached with a
+// TTY and stdin (docker run -it without -d). Recreating it detached would
+// leave nothing attached to the terminal, so it is run with -it instead.
+func isInteractive(info *ContainerInfo) bool {
+	return info.Config.Tty && info.Config.OpenStdin && info.Config.AttachStdin
+}
+
+// tmpfsFlag renders a --tmpfs value, normalizing size= to bytes so the
+// command is the same however the size was originally spelled. Malformed
+// sizes are dropped with a warning since docker would refuse them.
+func tmpfsFlag(path, options string) string {
+	if options == "" {
+		return path
+	}
+
+	var kept []string
+	for _, option := range strings.Split(options, ",") {
+		if value, ok := strings.CutPrefix(option, "size="); ok && !strings.HasSuffix(value, "%") {
+			size, err := parseSize(value)
+			if err != nil || size <= 0 {
+				printWarning("Dropping invalid tmpfs option %q for %s\n", option, path)
+				continue
+			}
+			option = fmt.Sprintf("size=%d", size)
+		}
+		kept = append(kept, option)
+	}
+
+	if len(kept) == 0 {
+		return path
+	}
+	return path + ":" + strings.Join(kept, ",")
+}
+
+// hasWritableTmpfs reports whether any tmpfs mount is writable, i.e. not
+// mounted with the ro option.
+func hasWritableTmpfs(tmpfs map[string]string) bool {
+	for _, options := range tmpfs {
+		writable := true
+		for _, option := range strings.Split(options, ",") {
+			writable = writable && option != "ro"
+		}
+		if writable {
+			return true
+		}
+	}
+	return false
+}
+
+// portFlag renders a -p value. The protocol is always spelled out, so
+// 53/udp and 53/tcp stay distinct, and a host IP other than the wildcard
+// is kept (bracketed for IPv6).
+func portFlag(binding Port, port string) string {
+	if !strings.Contains(port, "/") {
+		port += "/tcp"
+	}
+	value := binding.HostPort + ":" + port
+	switch ip := binding.HostIP; {
+	case ip == "" || ip == "0.0.0.0" || ip == "::":
+	case strings.Contains(ip, ":"):
+		value = "[" + ip + "]:" + value
+	default:
+		value = ip + ":" + value
+	}
+	return value
+}
+
+// sortedPorts orders PortBindings keys by port number, then protocol, so
+// the generated command does not change between runs.
+func sortedPorts(bindings map[string][]Port) []string {
+	ports := make([]string, 0, len(bindings))
+	for port := range bindings {
+		ports = append(ports, port)
+	}
+	sort.Slice(ports, func(i, j int) bool {
+		a, aProto, _ := strings.Cut(ports[i], "/")
+		b, bProto, _ := strings.Cut(ports[j], "/")
+		an, _ := strconv.Atoi(strings.SplitN(a, "-", 2)[0])
+		bn, _ := strconv.Atoi(strings.SplitN(b, "-", 2)[0])
+		if an != bn {
+			return an < bn
+		}
+		return aProto < bProto
+	})
+	return ports
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// volumesFromFlag re-emits a VolumesFrom entry ("container" or
+// "container:ro"/"container:rw") with its access mode intact. Unknown modes
+// are dropped with a warning, falling back to docker's default of inheriting
+// the source container's mode.
+func volumesFromFlag(source string) string {
+	name, mode, ok := strings.Cut(source, ":")
+	if !ok {
+		return source
+	}
+	switch mode {
+	case "ro", "rw":
+		return name + ":" + mode
+	}
+	printWarning("Ignoring unknown --volumes-from mode %q for %s\n", mode, name)
+	return name
+}
+
+// anonymousVolume matches the generated names of anonymous volumes.
+var anonymousVolume = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// volumeFlags returns the -v values for the container's storage: Binds as
+// they are, followed by the named volumes and binds from Mounts that Binds
+// does not already cover (mounts created with -v show up in both). Anonymous
+// volumes are left to the image's VOLUME declarations, which create fresh
+// ones for the new container.
+func volumeFlags(info *ContainerInfo) []string {
+	volumes := append([]string(nil), info.HostConfig.Binds...)
+	covered := make(map[string]bool)
+	for _, bind := range info.HostConfig.Binds {
+		if parts := strings.Split(bind, ":"); len(parts) >= 2 {
+			covered[parts[1]] = true
+		}
+	}
+
+	for _, mount := range info.Mounts {
+		if covered[mount.Destination] {
+			continue
+		}
+		var value string
+		switch mount.Type {
+		case "volume":
+			if anonymousVolume.MatchString(mount.Name) {
+				continue
+			}
+			value = mount.Name + ":" + mount.Destination
+		case "bind":
+			value = mount.Source + ":" + mount.Destination
+		default:
+			continue
+		}
+		if !mount.RW {
+			value += ":ro"
+		}
+		covered[mount.Destination] = true
+		volumes = append(volumes, value)
+	}
+	return volumes
+}
+
+// logArgs re-emits the log driver and its options. The daemon reports its
+// default of json-file without options for containers that set neither, and
+// that is left out so a changed daemon default still applies.
+func logArgs(config LogConfig) []string {
+	if config.Type == "" || config.Type == "json-file" && len(config.Config) == 0 {
+		return nil
+	}
+	parts := []string{"--log-driver", config.Type}
+	for _, key := range sortedKeys(config.Config) {
+		parts = append(parts, "--log-opt", key+"="+config.Config[key])
+	}
+	return parts
+}
+
+// deviceFlag renders a --device value as host[:container][:perms], with the
+// permissions only spelled out when they differ from docker's default rwm.
+func deviceFlag(device Device) string {
+	value := device.PathOnHost
+	perms := device.CgroupPermissions
+	if perms == "rwm" {
+		perms = ""
+	}
+	if perms != "" || device.PathInContainer != "" && device.PathInContainer != device.PathOnHost {
+		value += ":" + cmp.Or(device.PathInContainer, device.PathOnHost)
+	}
+	if perms != "" {
+		value += ":" + perms
+	}
+	return value
+}
+
+// parseLink splits a HostConfig.Links entry, which docker stores as
+// "/target:/container/alias", into the linked container and its alias.
+func parseLink(link string) (name, alias string) {
+	target, path, _ := strings.Cut(link, ":")
+	name = strings.TrimPrefix(target, "/")
+	alias = path[strings.LastIndex(path, "/")+1:]
+	if alias == "" {
+		alias = name
+	}
+	return name, alias
+}
+
+// runLabels are the labels to pass to docker run: Config.Labels minus the
+// ones inherited unchanged from the image, which the new container gets from
+// the image again. The result is cached on info since buildRunArgs runs more
+// than once per container.
+func runLabels(info *ContainerInfo) map[string]string {
+	if info.labels != nil {
+		return info.labels
+	}
+	info.labels = make(map[string]string, len(info.Config.Labels))
+
+	var inherited map[string]string
+	if len(info.Config.Labels) > 0 {
+		image, err := imageDefaults(info)
+		if err != nil {
+			printWarning("Could not read image labels, keeping all container labels: %v\n", err)
+		} else {
+			inherited = image.Labels
+		}
+	}
+	for key, value := range info.Config.Labels {
+		if imageValue, ok := inherited[key]; !ok || imageValue != value {
+			info.labels[key] = value
+		}
+	}
+	return info.labels
+}
+
+// imageDefaults returns the config of the image the container was created
+// from, which tells inherited settings apart from run-time overrides.
+func imageDefaults(info *ContainerInfo) (*imageConfig, error) {
+	if info.image != nil {
+		return info.image, nil
+	}
+	image, err := getImageConfig(info.ImageID)
+	if err != nil {
+		return nil, err
+	}
+	info.image = image
+	return image, nil
+}
+
+// imageOverrideArgs re-emits --user and --workdir when they differ from the
+// image's USER and WORKDIR; inherited values are left to the image. Without
+// the image config any value that is set is kept.
+func imageOverrideArgs(info *ContainerInfo) []string {
+	var user, workdir string
+	if info.Config.User != "" || info.Config.WorkingDir != "" {
+		image, err := imageDefaults(info)
+		if err != nil {
+			printWarning("Could not read the image user and workdir, keeping the container's: %v\n", err)
+		} else {
+			user, workdir = image.User, image.WorkingDir
+		}
+	}
+
+	var parts []string
+	if info.Config.User != "" && info.Config.User != user {
+		parts = append(parts, "--user", info.Config.User)
+	}
+	if info.Config.WorkingDir != "" && info.Config.WorkingDir != workdir {
+		parts = append(parts, "--workdir", info.Config.WorkingDir)
+	}
+	return parts
+}
+
+// entrypointArgs re-emits an entrypoint set with docker run --entrypoint.
+// One inherited from the image is left out so a new image can change it.
+// --entrypoint takes a single executable, so any further elements go in
+// front of the command; an entrypoint cleared with --entrypoint "" is
+// re-emitted as such.
+func entrypointArgs(info *ContainerInfo) (flags, cmd []string) {
+	entrypoint := info.Config.Entrypoint
+	image, err := imageDefaults(info)
+	if err != nil {
+		if len(entrypoint) == 0 {
+			return nil, nil
+		}
+		printWarning("Could not read the image entrypoint, keeping the container's: %v\n", err)
+	} else if slices.Equal(entrypoint, image.Entrypoint) {
+		return nil, nil
+	}
+	if len(entrypoint) == 0 {
+		return []string{"--entrypoint", ""}, nil
+	}
+	return []string{"--entrypoint", entrypoint[0]}, entrypoint[1:]
+}
+
+// labelFilePath expands the {name} placeholder of --label-file so several
+// containers can be handled in one run without sharing a file.
+func labelFilePath(info *ContainerInfo) string {
+	return strings.ReplaceAll(opts.labelFile, "{name}", strings.TrimPrefix(info.Name, "/"))
+}
+
+// labelKeys orders the labels to emit according to --label-order: sorted by
+// key for stable diffs, or in the order the inspect output lists them. Docker
+// itself reports labels sorted, so "original" only differs for runtimes that
+// keep insertion order.
+func labelKeys(info *ContainerInfo, labels map[string]string) []string {
+	if opts.labelOrder != "original" {
+		return sortedKeys(labels)
+	}
+	keys, err := rawLabelOrder(info.raw)
+	if err != nil {
+		printWarning("Could not read label order, sorting labels: %v\n", err)
+		return sortedKeys(labels)
+	}
+	ordered := make([]string, 0, len(labels))
+	for _, key := range keys {
+		if _, ok := labels[key]; ok {
+			ordered = append(ordered, key)
+		}
+	}
+	return ordered
+}
+
+// rawLabelOrder returns the keys of Config.Labels in the order they appear
+// in the inspect JSON, which decoding into a map loses.
+func rawLabelOrder(raw json.RawMessage) ([]string, error) {
+	var container struct {
+		Config struct {
+			Labels json.RawMessage `json:"Labels"`
+		} `json:"Config"`
+	}
+	if err := json.Unmarshal(raw, &container); err != nil {
+		return nil, err
+	}
+	if len(container.Config.Labels) == 0 || string(container.Config.Labels) == "null" {
+		return nil, nil
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(container.Config.Labels))
+	if _, err := dec.Token(); err != nil {
+		return nil, err
+	}
+	var keys []string
+	for dec.More() {
+		token, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		var value string
+		if err := dec.Decode(&value); err != nil {
+			return nil, err
+		}
+		keys = append(keys, token.(string))
+	}
+	return keys, nil
+}
+
+// writeLabelFile writes labels in the key=value per line format read by
+// `docker run --label-file`, in the order given by keys.
+func writeLabelFile(path string, keys []string, labels map[string]string) error {
+	var b strings.Builder
+	for _, key := range keys {
+		value := labels[key]
+		if strings.ContainsAny(value, "\r\n") {
+			printWarning("Skipping label %s: multi-line values cannot be stored in a label file\n", key)
+			continue
+		}
+		fmt.Fprintf(&b, "%s=%s\n", key, value)
+	}
+	return os.WriteFile(path, []byte(b.String()), 0o644)
+}
+
+// namespacedSysctls are the non-prefix sysctls docker run accepts; anything
+// else must live under fs.mqueue. or net.
+var namespacedSysctls = map[string]bool{
+	"kernel.msgmax": true, "kernel.msgmnb": true, "kernel.msgmni": true, "kernel.sem": true,
+	"kernel.shmall": true, "kernel.shmmax": true, "kernel.shmmni": true, "kernel.shm_rmid_forced": true,
+}
+
+// sysctlFlag renders a --sysctl value with the dotted key form docker
+// accepts (net/ipv4/ip_forward becomes net.ipv4.ip_forward) and warns about
+// keys docker run would reject.
+func sysctlFlag(key, value, networkMode string) string {
+	key = strings.ReplaceAll(key, "/", ".")
+	switch {
+	case strings.HasPrefix(key, "net."):
+		if networkMode == "host" {
+			printWarning("Sysctl %s is not allowed with the host network; docker run will reject it\n", key)
+		}
+	case strings.HasPrefix(key, "fs.mqueue."), namespacedSysctls[key]:
+	default:
+		printWarning("Sysctl %s is not namespaced; docker run will reject it\n", key)
+	}
+	return key + "=" + value
+}
+
+// restartPolicyFlag renders a restart policy in the form accepted by
+// `run --restart` of the configured runtime. A retry count is only valid on
+// on-failure, so MaximumRetryCount is ignored for every other policy.
+// Podman has no distinct unless-stopped and treats it as always, so that
+// mapping is made explicit; policies the runtime does not know are dropped.
+func restartPolicyFlag(policy RestartPolicy) string {
+	switch policy.Name {
+	case "", "no":
+		return ""
+	case "on-failure":
+		if policy.MaximumRetryCount > 0 {
+			return fmt.Sprintf("on-failure:%d", policy.MaximumRetryCount)
+		}
+		return policy.Name
+	case "always":
+		return policy.Name
+	case "unless-stopped":
+		if isPodman() {
+			printWarning("Podman treats restart policy unless-stopped as always, using always\n")
+			return "always"
+		}
+		return policy.Name
+	}
+	printWarning("Dropping restart policy %q, which %s does not support\n", policy.Name, runtimeBinary())
+	return ""
+}
+
+// dockerDefaultCaps is the capability bounding set docker grants containers
+// by default. Adding one of these or dropping anything outside of it is a
+// no-op, so only genuine changes are re-emitted.
+var dockerDefaultCaps = []string{
+	"AUDIT_WRITE", "CHOWN", "DAC_OVERRIDE", "FOWNER", "FSETID", "KILL", "MKNOD",
+	"NET_BIND_SERVICE", "NET_RAW", "SETFCAP", "SETGID", "SETPCAP", "SETUID", "SYS_CHROOT",
+}
+
+// normalizeCapability turns "cap_net_admin" and "NET_ADMIN" into the same key.
+func normalizeCapability(capability string) string {
+	return strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(capability)), "CAP_")
+}
+
+func capabilitySet(caps []string) map[string]bool {
+	set := make(map[string]bool, len(caps))
+	for _, capability := range caps {
+		if capability = normalizeCapability(capability); capability != "" {
+			set[capability] = true
+		}
+	}
+	return set
+}
+
+// shouldSkipEnv reports whether an env var is one of the skipped prefixes,
+// see skipEnvPrefixes.
+func shouldSkipEnv(env string) bool {
+	for _, prefix := range skipEnvPrefixes {
+		if strings.HasPrefix(env, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// confirmExecution asks the user whether to run the generated command. Any
+// change summary lines and the --confirm-message are printed first so the
+// prompt has context. A group that was already confirmed as a whole is not
+// asked about again.
+func confirmExecution(changes []string) bool {
+	if len(changes) > 0 {
+		printInfo("Changes:\n")
+		for _, line := range changes {
+			fmt.Fprintf(messages, "  %s\n", line)
+		}
+		fmt.Fprintln(messages)
+	}
+
+	if opts.groupConfirmed {
+		return true
+	}
+	if opts.confirmMessage != "" && !opts.yes {
+		printWarning("%s\n", opts.confirmMessage)
+	}
+	return askYesNo("Do you want to execute this command? (y/N): ")
+}
+
+// stdinReader is shared by all prompts so buffered input meant for a later
+// prompt is not lost between them.
+var stdinReader = bufio.NewReader(os.Stdin)
+
+// promptInput is where prompts are answered from: stdin, or the terminal
+// after --stdin consumed stdin. It is nil when no terminal is available.
+var promptInput = os.Stdin
+
+// askYesNo prompts for confirmation. With --yes every prompt is answered
+// yes without reading stdin.
+func askYesNo(prompt string) bool {
+	if opts.yes {
+		return true
+	}
+	printPrompt(prompt)
+
+	response, err := stdinReader.ReadString('\n')
+	if err != nil {
+		return false
+	}
+
+	response = strings.TrimSpace(strings.ToLower(response))
+	return response == "y" || response == "yes"
+}
+
+// executeCommand runs an argv directly, without a shell, so arguments with
+// spaces or shell metacharacters reach docker unchanged.
+func executeCommand(ctx context.Context, args []string, stderr io.Writer) error {
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = commandOutput
+	cmd.Stderr = stderr
+	return cmd.Run()
+}
+
+// executeRunCommand runs the generated docker run command, bounded by
+// --run-timeout. With --keep-name-on-conflict it retries for a bounded time
+// when docker reports the name is still taken, which happens when `docker rm`
+// returns before the daemon has released the old container's name.
+func executeRunCommand(args []string) error {
+	ctx, cancel := stepContext(opts.runTimeout)
+	defer cancel()
+
+	if !opts.keepNameRetry {
+		return stepError(ctx, "docker run", opts.runTimeout, executeCommand(ctx, args, os.Stderr))
+	}
+
+	deadline := time.Now().Add(opts.nameReleaseWindow)
+	for {
+		var stderr strings.Builder
+		err := executeCommand(ctx, args, io.MultiWriter(os.Stderr, &stderr))
+		if err == nil || !isNameConflict(stderr.String()) {
+			return stepError(ctx, "docker run", opts.runTimeout, err)
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("container name still in use after %s: %v", opts.nameReleaseWindow, err)
+		}
+		printWarning("Container name is still in use, retrying...\n")
+		time.Sleep(500 * time.Millisecond)
+	}
+}
+
+// stepContext bounds a single docker operation. A zero timeout means no
+// deadline.
+func stepContext(timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(context.Background())
+	}
+	return context.WithTimeout(context.Background(), timeout)
+}
+
+// stepError reports a step killed by its deadline as a timeout rather than
+// the "signal: killed" exec returns.
+func stepError(ctx context.Context, step string, timeout time.Duration, err error) error {
+	if err != nil && ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("%s timed out after %s", step, timeout)
+	}
+	return err
+}
+
+func isNameConflict(stderr string) bool {
+	return strings.Contains(stderr, "is already in use by container")
+}
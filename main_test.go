@@ -0,0 +1,669 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// runValueFlags are the docker run flags drun emits that take a value; the
+// ones without a value are runBoolFlags.
+var runValueFlags = map[string]bool{
+	"--name": true, "--platform": true, "--restart": true, "--cpus": true, "--cpu-quota": true,
+	"--cpu-period": true, "--cpu-shares": true, "--memory": true, "--memory-swap": true,
+	"--oom-score-adj": true, "--stop-timeout": true, "-v": true, "--volumes-from": true,
+	"--link": true, "--add-host": true, "--dns": true, "--dns-search": true, "--dns-option": true,
+	"--tmpfs": true, "--sysctl": true, "-p": true, "--env-file": true, "-e": true, "-l": true,
+	"--label-file": true, "--cap-add": true, "--cap-drop": true, "--ulimit": true,
+	"--annotation": true, "--group-add": true, "--device": true, "--device-cgroup-rule": true,
+	"--health-cmd": true, "--health-interval": true, "--health-timeout": true,
+	"--health-start-period": true, "--health-start-interval": true, "--health-retries": true,
+	"--log-driver": true, "--log-opt": true, "--cidfile": true, "--network": true,
+	"--network-alias": true, "--user": true, "--workdir": true, "--entrypoint": true,
+}
+
+// checkRunSyntax fails the test unless argv is a docker run command line
+// docker would parse: known flags only, each value flag followed by its
+// value, then the image and the command.
+func checkRunSyntax(t *testing.T, argv []string) {
+	t.Helper()
+	if len(argv) < 3 || argv[1] != "run" {
+		t.Fatalf("not a run command: %q", argv)
+	}
+	for i := 2; i < len(argv); i++ {
+		arg := argv[i]
+		switch {
+		case !strings.HasPrefix(arg, "-"):
+			return
+		case runBoolFlags[arg]:
+		case runValueFlags[arg]:
+			if i+1 == len(argv) {
+				t.Fatalf("%s has no value in %q", arg, argv)
+			}
+			i++
+		default:
+			t.Fatalf("unknown flag %s in %q", arg, argv)
+		}
+	}
+	t.Fatalf("no image in %q", argv)
+}
+
+// testContainer parses a minimal busybox container named app; config and
+// hostConfig are the members of its Config and HostConfig objects.
+func testContainer(t *testing.T, config, hostConfig string) *ContainerInfo {
+	t.Helper()
+	if config != "" {
+		config = "," + config
+	}
+	return parseInfo(t, fmt.Sprintf(`{"Id":"abc123","Name":"/app","Image":"sha256:old",
+		"Config":{"Image":"busybox:1.36"%s},"HostConfig":{%s}}`, config, hostConfig))
+}
+
+// flagValues returns the values argv passes to the flag name, in order.
+func flagValues(argv []string, name string) []string {
+	var values []string
+	for i := 0; i+1 < len(argv); i++ {
+		if argv[i] == name {
+			values = append(values, argv[i+1])
+			i++
+		}
+	}
+	return values
+}
+
+// manyFlagsArgv is the command recreating testdata/many-flags.json.
+var manyFlagsArgv = []string{
+	"docker", "run", "-d", "--name", "web",
+	"--restart", "on-failure:3",
+	"--cpus", "1.5", "--cpu-shares", "512", "--memory", "512m", "--memory-swap", "-1",
+	"--oom-score-adj", "-500",
+	"--stop-timeout", "60",
+	"-v", "/srv/web:/usr/share/nginx/html:ro",
+	"--volumes-from", "config:ro",
+	"--link", "db:database",
+	"--add-host", "host.docker.internal:host-gateway",
+	"--dns", "10.0.0.2", "--dns-search", "corp.example",
+	"--read-only", "--tmpfs", "/tmp:size=67108864",
+	"--sysctl", "net.core.somaxconn=1024",
+	"-p", "8080:80/tcp", "-p", "127.0.0.1:8443:443/tcp",
+	"-e", "APP_MODE=production", "-e", "JAVA_OPTS=-Xmx512m -Dfoo=bar",
+	"-l", "app=web", "-l", "traefik.enable=true",
+	"--cap-add", "NET_ADMIN", "--cap-drop", "MKNOD",
+	"--ulimit", "nofile=65536:65536",
+	"--device", "/dev/snd",
+	"--health-cmd", "curl -f http://localhost/ || exit 1", "--health-interval", "30s", "--health-retries", "3",
+	"--log-driver", "json-file", "--log-opt", "max-size=10m",
+	"--network", "appnet", "--network-alias", "frontend",
+	"--user", "1000:1000", "--workdir", "/app",
+	"nginx:1.25", "nginx", "-g", "daemon off;",
+}
+
+func TestBuildRunArgsManyFlags(t *testing.T) {
+	resetState(t)
+	info := parseInfo(t, fixture(t, "many-flags"))
+
+	got := buildRunArgs(info)
+	if want := manyFlagsArgv; !slices.Equal(got, want) {
+		t.Errorf("buildRunArgs:\ngot  %q\nwant %q", got, want)
+	}
+	checkRunSyntax(t, got)
+}
+
+func TestCapabilityFlags(t *testing.T) {
+	tests := []struct {
+		name        string
+		defaultCaps string
+		capAdd      string
+		capDrop     string
+		wantAdd     []string
+		wantDrop    []string
+	}{
+		{"adding a default is a no-op", "", `["CHOWN","NET_ADMIN"]`, `null`, []string{"NET_ADMIN"}, nil},
+		{"dropping a non-default is a no-op", "", `null`, `["SYS_ADMIN","MKNOD"]`, nil, []string{"MKNOD"}},
+		{"cap_ prefix and case", "", `["cap_net_admin","cap_chown"]`, `["cap_mknod"]`, []string{"cap_net_admin"}, []string{"cap_mknod"}},
+		{"drop ALL keeps every add", "", `["CHOWN","NET_BIND_SERVICE"]`, `["ALL"]`, []string{"CHOWN", "NET_BIND_SERVICE"}, []string{"ALL"}},
+		{"add ALL keeps every drop", "", `["ALL"]`, `["SYS_ADMIN"]`, []string{"ALL"}, []string{"SYS_ADMIN"}},
+		{"custom default set", "CHOWN,KILL", `["CHOWN","MKNOD"]`, `["KILL","NET_RAW"]`, []string{"MKNOD"}, []string{"KILL"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetState(t)
+			if tt.defaultCaps != "" {
+				opts.defaultCaps = tt.defaultCaps
+			}
+			info := testContainer(t, "", fmt.Sprintf(`"CapAdd":%s,"CapDrop":%s`, tt.capAdd, tt.capDrop))
+
+			argv := buildRunArgs(info)
+			if got := flagValues(argv, "--cap-add"); !slices.Equal(got, tt.wantAdd) {
+				t.Errorf("--cap-add %q, want %q", got, tt.wantAdd)
+			}
+			if got := flagValues(argv, "--cap-drop"); !slices.Equal(got, tt.wantDrop) {
+				t.Errorf("--cap-drop %q, want %q", got, tt.wantDrop)
+			}
+		})
+	}
+}
+
+func TestTmpfsFlag(t *testing.T) {
+	tests := []struct {
+		options string
+		want    string
+		warns   bool
+	}{
+		{"", "/tmp", false},
+		{"size=64m", "/tmp:size=67108864", false},
+		{"rw,noexec,size=1g", "/tmp:rw,noexec,size=1073741824", false},
+		{"size=50%", "/tmp:size=50%", false},
+		{"size=lots,mode=1777", "/tmp:mode=1777", true},
+		{"size=0", "/tmp", true},
+	}
+	for _, tt := range tests {
+		out := resetState(t)
+		if got := tmpfsFlag("/tmp", tt.options); got != tt.want {
+			t.Errorf("tmpfsFlag(%q) = %q, want %q", tt.options, got, tt.want)
+		}
+		if warned := strings.Contains(out.String(), "invalid tmpfs option"); warned != tt.warns {
+			t.Errorf("tmpfsFlag(%q) warned = %v, want %v", tt.options, warned, tt.warns)
+		}
+	}
+}
+
+func TestVolumesFromModes(t *testing.T) {
+	resetState(t)
+	info := testContainer(t, "", `"VolumesFrom":["config:ro","data:rw","cache"]`)
+
+	got := flagValues(buildRunArgs(info), "--volumes-from")
+	if want := []string{"config:ro", "data:rw", "cache"}; !slices.Equal(got, want) {
+		t.Errorf("--volumes-from %q, want %q", got, want)
+	}
+
+	out := resetState(t)
+	if got := volumesFromFlag("data:z"); got != "data" {
+		t.Errorf("volumesFromFlag(data:z) = %q, want data", got)
+	}
+	if !strings.Contains(out.String(), "unknown --volumes-from mode") {
+		t.Errorf("no warning for the unknown mode:\n%s", out)
+	}
+}
+
+func TestFormatCommand(t *testing.T) {
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"docker", "run", "-d", "nginx:1.25"}, "docker run -d nginx:1.25"},
+		{[]string{"-e", "JAVA_OPTS=-Xmx512m -Dfoo=bar"}, "-e 'JAVA_OPTS=-Xmx512m -Dfoo=bar'"},
+		{[]string{"--health-cmd", "curl -f http://localhost/ || exit 1"}, "--health-cmd 'curl -f http://localhost/ || exit 1'"},
+		{[]string{"-e", "MSG=it's $HOME"}, `-e 'MSG=it'\''s $HOME'`},
+		{[]string{"-l", ""}, "-l ''"},
+		{[]string{"sh", "-c", "echo *; `id`"}, "sh -c 'echo *; `id`'"},
+	}
+	for _, tt := range tests {
+		if got := formatCommand(tt.args); got != tt.want {
+			t.Errorf("formatCommand(%q) = %s, want %s", tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestFormatCommandRoundTrip(t *testing.T) {
+	args := []string{"a b", "it's", `"quoted"`, "$HOME", "back\\slash", "new\nline", "*", ""}
+	out, err := exec.Command("sh", "-c", `for a in "$@"; do printf '%s\0' "$a"; done`+" sh "+formatCommand(args)).Output()
+	if err != nil {
+		t.Skipf("no sh: %v", err)
+	}
+	if got := strings.Split(strings.TrimSuffix(string(out), "\x00"), "\x00"); !slices.Equal(got, args) {
+		t.Errorf("the shell read back %q, want %q", got, args)
+	}
+}
+
+func TestPrintedCommandQuotesCmd(t *testing.T) {
+	resetState(t)
+	info := testContainer(t, `"Cmd":["app","--flag=a b","plain"]`, "")
+
+	got := displayCommand(buildRunArgs(info))
+	if want := "busybox:1.36 app '--flag=a b' plain"; !strings.HasSuffix(got, want) {
+		t.Errorf("printed command = %s, want it to end in %s", got, want)
+	}
+}
+
+func TestRestartPolicyFlag(t *testing.T) {
+	tests := []struct {
+		runtime string
+		policy  RestartPolicy
+		want    string
+		warns   bool
+	}{
+		{"docker", RestartPolicy{Name: "unless-stopped"}, "unless-stopped", false},
+		{"podman", RestartPolicy{Name: "unless-stopped"}, "always", true},
+		{"podman", RestartPolicy{Name: "on-failure", MaximumRetryCount: 5}, "on-failure:5", false},
+		{"docker", RestartPolicy{Name: "on-failure"}, "on-failure", false},
+		{"podman", RestartPolicy{Name: "always"}, "always", false},
+		{"docker", RestartPolicy{Name: "no"}, "", false},
+		{"podman", RestartPolicy{Name: "sometimes"}, "", true},
+	}
+	for _, tt := range tests {
+		out := resetState(t)
+		opts.runtime = tt.runtime
+		if got := restartPolicyFlag(tt.policy); got != tt.want {
+			t.Errorf("%s %+v: got %q, want %q", tt.runtime, tt.policy, got, tt.want)
+		}
+		if warned := out.Len() > 0; warned != tt.warns {
+			t.Errorf("%s %+v: warned = %v, want %v", tt.runtime, tt.policy, warned, tt.warns)
+		}
+	}
+}
+
+func TestParseFlagsGroup(t *testing.T) {
+	resetState(t)
+	f := newFakeDocker(t)
+	f.answer("web\napi\ndb\n", "docker", "ps", "--format", "{{.Names}}")
+	f.answer("web\napi\n", "docker", "ps", "--format", "{{.Names}}", "--filter", "label=tier=web")
+
+	names, code := parseFlags([]string{"--group", "tier=web"})
+	if code != 0 || !slices.Equal(names, []string{"web", "api"}) {
+		t.Errorf("--group tier=web selected %q (code %d), want web and api", names, code)
+	}
+
+	for _, args := range [][]string{
+		{"--all", "--group", "tier=web"},
+		{"--group", "tier=web", "--all"},
+		{"--group", "tier=web", "db"},
+		{"--group", "tier"},
+		{"--all", "db"},
+	} {
+		before := f.count("docker", "ps")
+		if _, code := parseFlags(args); code != 2 {
+			t.Errorf("%q: code = %d, want 2", args, code)
+		}
+		if f.count("docker", "ps") != before {
+			t.Errorf("%q: listed containers before rejecting the command line", args)
+		}
+	}
+}
+
+func TestUnlimitedUlimit(t *testing.T) {
+	resetState(t)
+	info := testContainer(t, "", `"Ulimits":[{"Name":"memlock","Soft":-1,"Hard":-1},{"Name":"nofile","Soft":1024,"Hard":-1}]`)
+
+	got := flagValues(buildRunArgs(info), "--ulimit")
+	if want := []string{"memlock=-1:-1", "nofile=1024:-1"}; !slices.Equal(got, want) {
+		t.Errorf("--ulimit %q, want %q", got, want)
+	}
+}
+
+func TestReadOnlyWithTmpfs(t *testing.T) {
+	tests := []struct {
+		name  string
+		tmpfs string
+		want  []string
+		warns bool
+	}{
+		{"writable tmpfs", `{"/tmp":"size=64m","/run":""}`, []string{"--read-only", "--tmpfs", "/run", "--tmpfs", "/tmp:size=67108864"}, false},
+		{"read-only tmpfs only", `{"/cache":"ro"}`, []string{"--read-only", "--tmpfs", "/cache:ro"}, true},
+		{"no tmpfs", `null`, []string{"--read-only"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := resetState(t)
+			info := testContainer(t, "", `"ReadonlyRootfs":true,"Tmpfs":`+tt.tmpfs)
+
+			argv := buildRunArgs(info)
+			i := slices.Index(argv, "--read-only")
+			if i < 0 || !slices.Equal(argv[i:i+len(tt.want)], tt.want) {
+				t.Errorf("argv = %q, want %q together", argv, tt.want)
+			}
+			if warned := strings.Contains(out.String(), "no writable tmpfs"); warned != tt.warns {
+				t.Errorf("warned = %v, want %v", warned, tt.warns)
+			}
+		})
+	}
+}
+
+func TestEnvOrderWithDuplicate(t *testing.T) {
+	resetState(t)
+	info := testContainer(t, `"Env":["ZED=1","ALPHA=2","MODE=dev","PATH=/bin","MODE=prod","BETA=3"]`, "")
+
+	got := flagValues(buildRunArgs(info), "-e")
+	if want := []string{"ZED=1", "ALPHA=2", "MODE=dev", "MODE=prod", "BETA=3"}; !slices.Equal(got, want) {
+		t.Errorf("-e %q, want the original order with the duplicate last %q", got, want)
+	}
+}
+
+func TestPortProtocols(t *testing.T) {
+	resetState(t)
+	info := testContainer(t, "", `"PortBindings":{
+		"53/tcp":[{"HostIp":"","HostPort":"53"}],
+		"53/udp":[{"HostIp":"","HostPort":"53"}],
+		"8125/udp":[{"HostIp":"127.0.0.1","HostPort":"8125"}],
+		"80/tcp":[{"HostIp":"::","HostPort":"8080"}]}`)
+
+	got := flagValues(buildRunArgs(info), "-p")
+	want := []string{"53:53/tcp", "53:53/udp", "8080:80/tcp", "127.0.0.1:8125:8125/udp"}
+	if !slices.Equal(got, want) {
+		t.Errorf("-p %q, want %q", got, want)
+	}
+}
+
+func TestGroupAddKeepGroups(t *testing.T) {
+	for _, tt := range []struct {
+		runtime string
+		want    []string
+		warns   bool
+	}{
+		{"podman", []string{"keep-groups", "video"}, false},
+		{"docker", []string{"video"}, true},
+	} {
+		t.Run(tt.runtime, func(t *testing.T) {
+			out := resetState(t)
+			opts.runtime = tt.runtime
+			info := testContainer(t, "", `"GroupAdd":["keep-groups","video"]`)
+
+			if got := flagValues(buildRunArgs(info), "--group-add"); !slices.Equal(got, tt.want) {
+				t.Errorf("--group-add %q, want %q", got, tt.want)
+			}
+			if warned := strings.Contains(out.String(), "keep-groups"); warned != tt.warns {
+				t.Errorf("warned = %v, want %v", warned, tt.warns)
+			}
+		})
+	}
+}
+
+func TestConfirmMessage(t *testing.T) {
+	const message = "This host runs PRODUCTION containers"
+	out := resetState(t)
+	opts.confirmMessage = message
+	stdinReader.Reset(strings.NewReader("y\n"))
+
+	if !confirmExecution(nil) {
+		t.Fatal("confirmExecution = false after answering y")
+	}
+	printed := out.String()
+	msg, prompt := strings.Index(printed, message), strings.Index(printed, "Do you want to execute this command?")
+	if msg < 0 || prompt < 0 || msg > prompt {
+		t.Errorf("want the message before the prompt:\n%s", printed)
+	}
+}
+
+func TestConfirmMessageFromConfig(t *testing.T) {
+	resetState(t)
+	path := filepath.Join(t.TempDir(), "drun.json")
+	if err := os.WriteFile(path, []byte(`{"confirm_message": "from the file"}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, code := parseFlags([]string{"--config", path, "web"}); code != 0 || opts.confirmMessage != "from the file" {
+		t.Errorf("confirm message = %q (code %d), want the config file's", opts.confirmMessage, code)
+	}
+	if _, code := parseFlags([]string{"--config", path, "--confirm-message", "from the flag", "web"}); code != 0 || opts.confirmMessage != "from the flag" {
+		t.Errorf("confirm message = %q (code %d), want the flag to win", opts.confirmMessage, code)
+	}
+}
+
+func TestAnnotationsPreserved(t *testing.T) {
+	resetState(t)
+	info := testContainer(t, `"Labels":{"com.docker.compose.project":"shop"}`,
+		`"Annotations":{"io.kubernetes.cri.container-type":"container","io.kubernetes.cri.sandbox-id":"abc","org.example/owner":"ops"}`)
+
+	got := flagValues(buildRunArgs(info), "--annotation")
+	want := []string{"io.kubernetes.cri.container-type=container", "io.kubernetes.cri.sandbox-id=abc", "org.example/owner=ops"}
+	if !slices.Equal(got, want) {
+		t.Errorf("--annotation %q, want %q", got, want)
+	}
+}
+
+func TestLabelOrder(t *testing.T) {
+	tests := []struct {
+		order string
+		want  []string
+	}{
+		{"original", []string{"zone=eu", "app=web", "maintainer=ops"}},
+		{"sorted", []string{"app=web", "maintainer=ops", "zone=eu"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.order, func(t *testing.T) {
+			resetState(t)
+			newFakeDocker(t)
+			opts.labelOrder = tt.order
+			info := testContainer(t, `"Labels":{"zone":"eu","app":"web","maintainer":"ops"}`, "")
+
+			if got := flagValues(buildRunArgs(info), "-l"); !slices.Equal(got, tt.want) {
+				t.Errorf("-l %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDNSSearch(t *testing.T) {
+	tests := []struct {
+		name      string
+		dnsSearch string
+		want      []string
+	}{
+		{"unset", `null`, nil},
+		{"empty", `[]`, nil},
+		{"disabled", `["."]`, []string{"."}},
+		{"domains", `["corp.example","svc.local"]`, []string{"corp.example", "svc.local"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetState(t)
+			info := testContainer(t, "", `"DnsSearch":`+tt.dnsSearch)
+
+			if got := flagValues(buildRunArgs(info), "--dns-search"); !slices.Equal(got, tt.want) {
+				t.Errorf("--dns-search %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUserAndWorkdir(t *testing.T) {
+	tests := []struct {
+		name          string
+		image         string
+		fail          bool
+		user, workdir []string
+	}{
+		{"image defaults", `{}`, false, []string{"1000:1000"}, []string{"/app"}},
+		{"inherited from the image", `{"User":"1000:1000","WorkingDir":"/app"}`, false, nil, nil},
+		{"overriding the image", `{"User":"nobody","WorkingDir":"/"}`, false, []string{"1000:1000"}, []string{"/app"}},
+		{"image config unavailable", "", true, []string{"1000:1000"}, []string{"/app"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetState(t)
+			f := newFakeDocker(t)
+			if tt.fail {
+				f.fail("No such image", "docker", "image", "inspect", "--format", "{{json .Config}}")
+			} else {
+				f.answer(tt.image, "docker", "image", "inspect", "--format", "{{json .Config}}")
+			}
+			info := testContainer(t, `"User":"1000:1000","WorkingDir":"/app"`, "")
+			info.image = nil // read the image config from the fake
+
+			argv := buildRunArgs(info)
+			if got := flagValues(argv, "--user"); !slices.Equal(got, tt.user) {
+				t.Errorf("--user %q, want %q", got, tt.user)
+			}
+			if got := flagValues(argv, "--workdir"); !slices.Equal(got, tt.workdir) {
+				t.Errorf("--workdir %q, want %q", got, tt.workdir)
+			}
+		})
+	}
+}
+
+func TestEntrypoint(t *testing.T) {
+	tests := []struct {
+		name       string
+		config     string
+		entrypoint []string
+		tail       []string // argv after the image
+	}{
+		{"multi-element override", `"Entrypoint":["/custom/start.sh","--config","/etc/app.yml"],"Cmd":["serve","--port","8080"]`,
+			[]string{"/custom/start.sh"}, []string{"--config", "/etc/app.yml", "serve", "--port", "8080"}},
+		{"single override", `"Entrypoint":["/custom/start.sh"],"Cmd":["serve"]`,
+			[]string{"/custom/start.sh"}, []string{"serve"}},
+		{"image default", `"Entrypoint":["/docker-entrypoint.sh"],"Cmd":["serve"]`,
+			nil, []string{"serve"}},
+		{"cleared", `"Cmd":["sh"]`,
+			[]string{""}, []string{"sh"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetState(t)
+			info := testContainer(t, tt.config, "")
+			info.image = &imageConfig{Entrypoint: []string{"/docker-entrypoint.sh"}}
+
+			argv := buildRunArgs(info)
+			if got := flagValues(argv, "--entrypoint"); !slices.Equal(got, tt.entrypoint) {
+				t.Errorf("--entrypoint %q, want %q", got, tt.entrypoint)
+			}
+			image := slices.Index(argv, "busybox:1.36")
+			if got := argv[image+1:]; !slices.Equal(got, tt.tail) {
+				t.Errorf("argv after the image = %q, want %q", got, tt.tail)
+			}
+		})
+	}
+}
+
+func TestDevices(t *testing.T) {
+	resetState(t)
+	info := testContainer(t, "", `"Devices":[
+		{"PathOnHost":"/dev/snd","PathInContainer":"/dev/snd","CgroupPermissions":"rwm"},
+		{"PathOnHost":"/dev/ttyUSB0","PathInContainer":"/dev/ttyACM0","CgroupPermissions":"rw"},
+		{"PathOnHost":"/dev/fuse","PathInContainer":"/dev/fuse","CgroupPermissions":"r"},
+		{"PathOnHost":"/dev/dri/renderD128","PathInContainer":"/dev/dri/card0","CgroupPermissions":"rwm"}]`)
+
+	got := flagValues(buildRunArgs(info), "--device")
+	want := []string{"/dev/snd", "/dev/ttyUSB0:/dev/ttyACM0:rw", "/dev/fuse:/dev/fuse:r", "/dev/dri/renderD128:/dev/dri/card0"}
+	if !slices.Equal(got, want) {
+		t.Errorf("--device %q, want %q", got, want)
+	}
+}
+
+func TestSysctlsAndUlimits(t *testing.T) {
+	tests := []struct {
+		name       string
+		hostConfig string
+		sysctls    []string
+		ulimits    []string
+		warning    string
+	}{
+		{"one of each", `"Sysctls":{"net.core.somaxconn":"1024"},"Ulimits":[{"Name":"nofile","Soft":65536,"Hard":65536}]`,
+			[]string{"net.core.somaxconn=1024"}, []string{"nofile=65536:65536"}, ""},
+		{"slash form and sorted", `"Sysctls":{"net/ipv4/ip_forward":"1","kernel.shmmax":"68719476736"}`,
+			[]string{"kernel.shmmax=68719476736", "net.ipv4.ip_forward=1"}, nil, ""},
+		{"net sysctl on the host network", `"NetworkMode":"host","Sysctls":{"net.core.somaxconn":"1024"}`,
+			[]string{"net.core.somaxconn=1024"}, nil, "not allowed with the host network"},
+		{"not namespaced", `"Sysctls":{"vm.swappiness":"10"}`, []string{"vm.swappiness=10"}, nil, "not namespaced"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := resetState(t)
+			argv := buildRunArgs(testContainer(t, "", tt.hostConfig))
+
+			if got := flagValues(argv, "--sysctl"); !slices.Equal(got, tt.sysctls) {
+				t.Errorf("--sysctl %q, want %q", got, tt.sysctls)
+			}
+			if got := flagValues(argv, "--ulimit"); !slices.Equal(got, tt.ulimits) {
+				t.Errorf("--ulimit %q, want %q", got, tt.ulimits)
+			}
+			if warned := out.String(); tt.warning == "" && warned != "" || !strings.Contains(warned, tt.warning) {
+				t.Errorf("warnings %q, want %q", warned, tt.warning)
+			}
+		})
+	}
+}
+
+func TestExtraHosts(t *testing.T) {
+	tests := []struct {
+		name       string
+		extraHosts string
+		want       []string
+	}{
+		{"host-gateway next to an IP", `["host.docker.internal:host-gateway","db.internal:10.0.0.5"]`,
+			[]string{"host.docker.internal:host-gateway", "db.internal:10.0.0.5"}},
+		{"two IP entries in order", `["db.internal:10.0.0.5","cache.internal:10.0.0.6"]`,
+			[]string{"db.internal:10.0.0.5", "cache.internal:10.0.0.6"}},
+		{"IPv6", `["v6.internal:fd00::5"]`, []string{"v6.internal:fd00::5"}},
+		{"none", `null`, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetState(t)
+			info := testContainer(t, "", `"ExtraHosts":`+tt.extraHosts)
+
+			if got := flagValues(buildRunArgs(info), "--add-host"); !slices.Equal(got, tt.want) {
+				t.Errorf("--add-host %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDeviceCgroupRules(t *testing.T) {
+	resetState(t)
+	info := testContainer(t, "", `"DeviceCgroupRules":["c 189:* rmw","b 8:0 r"]`)
+
+	got := flagValues(buildRunArgs(info), "--device-cgroup-rule")
+	if want := []string{"c 189:* rmw", "b 8:0 r"}; !slices.Equal(got, want) {
+		t.Errorf("--device-cgroup-rule %q, want %q", got, want)
+	}
+}
+
+func TestInteractiveContainer(t *testing.T) {
+	resetState(t)
+	info := parseInfo(t, fixture(t, "interactive"))
+
+	argv := buildRunArgs(info)
+	if want := []string{"docker", "run", "-it", "--name", "shell", "--network", "bridge", "alpine:3.20", "sh"}; !slices.Equal(argv, want) {
+		t.Errorf("argv = %q, want %q", argv, want)
+	}
+	if slices.Contains(argv, "-d") {
+		t.Errorf("interactive container recreated detached: %q", argv)
+	}
+}
+
+func TestPrintParsedInfo(t *testing.T) {
+	const env = `"Env":["APP_MODE=production","DB_PASSWORD=hunter2","API_TOKEN=t0k3n"]`
+	tests := []struct {
+		name        string
+		showSecrets bool
+		want        []string
+		hidden      []string
+	}{
+		{"redacted", false, []string{`"APP_MODE=production"`, `"DB_PASSWORD=***"`, `"API_TOKEN=***"`}, []string{"hunter2", "t0k3n"}},
+		{"--show-secrets", true, []string{`"APP_MODE=production"`, `"DB_PASSWORD=hunter2"`, `"API_TOKEN=t0k3n"`}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := resetState(t)
+			opts.showSecrets = tt.showSecrets
+			info := testContainer(t, env, "")
+
+			if err := printParsedInfo(info); err != nil {
+				t.Fatal(err)
+			}
+			printed := out.String()
+			if !strings.Contains(printed, "Parsed container info:") || !strings.Contains(printed, `"Image": "busybox:1.36"`) {
+				t.Errorf("no container dump:\n%s", printed)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(printed, want) {
+					t.Errorf("dump has no %s:\n%s", want, printed)
+				}
+			}
+			for _, secret := range tt.hidden {
+				if strings.Contains(printed, secret) {
+					t.Errorf("dump shows %s:\n%s", secret, printed)
+				}
+			}
+			// Only the dump is redacted; the recreate still gets the values.
+			if !slices.Contains(info.Config.Env, "DB_PASSWORD=hunter2") {
+				t.Errorf("printParsedInfo changed the container env: %q", info.Config.Env)
+			}
+		})
+	}
+}
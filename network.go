@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// extraNetworks returns the networks the container was attached to besides
+// the one passed to `docker run --network`. docker run accepts a single
+// network, so the rest have to be connected after the container starts.
+func extraNetworks(info *ContainerInfo) []string {
+	primary := info.HostConfig.NetworkMode
+	if primary == "" || primary == "default" {
+		primary = "bridge"
+	}
+	if primary == "host" || primary == "none" || strings.HasPrefix(primary, "container:") {
+		return nil
+	}
+
+	var names []string
+	for name := range info.NetworkSettings.Networks {
+		if name != primary {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// networkExists reports whether the runtime knows a network by that name.
+func networkExists(name string) bool {
+	return dockerCommand("network", "inspect", name).Run() == nil
+}
+
+// createNetwork recreates a network that was deleted since the container was
+// created. Only the name survives in the container's inspect output, so the
+// network comes back with the runtime's default driver and IPAM settings.
+func createNetwork(name string) error {
+	printWarning("Creating missing network %s; options such as driver and subnet are not reconstructed\n", name)
+	if err := dockerCommand("network", "create", name).Run(); err != nil {
+		return fmt.Errorf("failed to create network %s: %v", name, err)
+	}
+	return nil
+}
+
+// ensurePrimaryNetwork recreates the user-defined network passed to
+// --network when --create-missing-networks is set. Without it docker run
+// fails on the missing network and the error is reported as usual.
+func ensurePrimaryNetwork(info *ContainerInfo) error {
+	mode := info.HostConfig.NetworkMode
+	switch {
+	case !opts.createMissingNetworks, mode == "", mode == "default", mode == "bridge", mode == "host", mode == "none", strings.HasPrefix(mode, "container:"):
+		return nil
+	}
+	if networkExists(mode) {
+		return nil
+	}
+	return createNetwork(mode)
+}
+
+// networkAliases returns the aliases the user gave the container on a
+// network. Docker adds the container's name and short ID on its own; those
+// are left out, the ID in particular would be stale on the new container.
+func networkAliases(info *ContainerInfo, network string) []string {
+	name := strings.TrimPrefix(info.Name, "/")
+	var aliases []string
+	for _, alias := range info.NetworkSettings.Networks[network].Aliases {
+		if alias == name || len(alias) >= 12 && strings.HasPrefix(info.ID, alias) {
+			continue
+		}
+		aliases = append(aliases, alias)
+	}
+	return aliases
+}
+
+// connectNetworks reattaches the recreated container to its extra networks,
+// with the aliases it had on each.
+// A network that was deleted in the meantime only produces a warning: the
+// container is already running and failing the whole update over it would
+// leave the user with less than they started with. With
+// --create-missing-networks the network is recreated before connecting.
+func connectNetworks(info *ContainerInfo, networks []string) []string {
+	containerName := strings.TrimPrefix(info.Name, "/")
+	var notes []string
+	for _, name := range networks {
+		if !networkExists(name) {
+			if !opts.createMissingNetworks {
+				printWarning("Network %s no longer exists, not connecting %s to it\n", name, containerName)
+				notes = append(notes, "network "+name+" is missing and was not reconnected")
+				continue
+			}
+			if err := createNetwork(name); err != nil {
+				printWarning("%v\n", err)
+				notes = append(notes, "network "+name+" could not be recreated")
+				continue
+			}
+			notes = append(notes, "network "+name+" was recreated with default options")
+		}
+		args := []string{"network", "connect"}
+		for _, alias := range networkAliases(info, name) {
+			args = append(args, "--alias", alias)
+		}
+		args = append(args, name, containerName)
+		if err := dockerCommand(args...).Run(); err != nil {
+			printWarning("Failed to connect %s to network %s: %v\n", containerName, name, err)
+			notes = append(notes, "failed to reconnect network "+name)
+			continue
+		}
+		printInfo("Connected %s to network %s\n", containerName, name)
+	}
+	return notes
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+// twoNetworks returns the many-flags fixture attached to backend as well as
+// its primary network appnet.
+func twoNetworks(t *testing.T) string {
+	return strings.Replace(fixture(t, "many-flags"), `"Networks": {`, `"Networks": {"backend": {"NetworkID": "n2", "Aliases": ["api"]}, `, 1)
+}
+
+func TestMissingNetwork(t *testing.T) {
+	t.Run("warns and skips by default", func(t *testing.T) {
+		f, out := setupFlow(t)
+		f.inspect("web", twoNetworks(t))
+
+		result := processOne("web")
+		if result.Status != statusRestarted {
+			t.Fatalf("status = %s (%s), want %s", result.Status, result.Error, statusRestarted)
+		}
+		if f.count("docker", "network", "connect") > 0 || f.count("docker", "network", "create") > 0 {
+			t.Errorf("missing network was connected or created; calls:\n%s", f.dump())
+		}
+		if !strings.Contains(out.String(), "Network backend no longer exists") {
+			t.Errorf("no warning about the missing network:\n%s", out)
+		}
+		if len(result.Notes) != 1 || !strings.Contains(result.Notes[0], "backend") {
+			t.Errorf("notes = %q, want one about backend", result.Notes)
+		}
+	})
+
+	t.Run("is not looked up with --no-network-recreate", func(t *testing.T) {
+		f, _ := setupFlow(t)
+		f.inspect("web", twoNetworks(t))
+		opts.noNetworkRecreate = true
+
+		if result := processOne("web"); result.Status != statusRestarted {
+			t.Fatalf("status = %s (%s), want %s", result.Status, result.Error, statusRestarted)
+		}
+		if n := f.count("docker", "network"); n != 0 {
+			t.Errorf("%d network calls under --no-network-recreate; calls:\n%s", n, f.dump())
+		}
+	})
+
+	t.Run("an existing network is connected", func(t *testing.T) {
+		f, _ := setupFlow(t)
+		f.inspect("web", twoNetworks(t))
+		f.answer("", "docker", "network", "inspect", "backend")
+
+		if result := processOne("web"); result.Status != statusRestarted || len(result.Notes) != 0 {
+			t.Fatalf("result = %s %q %q, want a clean restart", result.Status, result.Error, result.Notes)
+		}
+		f.call("docker", "network", "connect", "--alias", "api", "backend", "web")
+	})
+}
+
+func TestCreateMissingNetworks(t *testing.T) {
+	f, out := setupFlow(t)
+	f.inspect("web", twoNetworks(t))
+	opts.createMissingNetworks = true
+
+	result := processOne("web")
+	if result.Status != statusRestarted {
+		t.Fatalf("status = %s (%s), want %s", result.Status, result.Error, statusRestarted)
+	}
+	// appnet is passed to docker run and has to exist first; backend is
+	// connected afterwards.
+	create, run := f.index("docker", "network", "create", "appnet"), f.index("docker", "run")
+	if create < 0 || create > run {
+		t.Errorf("appnet not created before docker run; calls:\n%s", f.dump())
+	}
+	create, connect := f.index("docker", "network", "create", "backend"), f.index("docker", "network", "connect")
+	if create < 0 || connect < 0 || create > connect {
+		t.Errorf("backend not created before the connect; calls:\n%s", f.dump())
+	}
+	if !strings.Contains(out.String(), "not reconstructed") {
+		t.Errorf("no warning that network options are lost:\n%s", out)
+	}
+}
+
+func TestNetworkAliases(t *testing.T) {
+	f, _ := setupFlow(t)
+	f.inspect("web", strings.Replace(fixture(t, "many-flags"), `"Networks": {`,
+		`"Networks": {"backend": {"NetworkID": "n2", "Aliases": ["api", "web", "4f1c2b3a5d6e", "api.internal"]}, "metrics": {"NetworkID": "n3"}, `, 1))
+	f.answer("", "docker", "network", "inspect")
+
+	if result := processOne("web"); result.Status != statusRestarted {
+		t.Fatalf("status = %s (%s), want %s", result.Status, result.Error, statusRestarted)
+	}
+	// The name and short ID docker adds itself are not passed back.
+	if got := flagValues(f.call("docker", "run"), "--network-alias"); !slices.Equal(got, []string{"frontend"}) {
+		t.Errorf("docker run --network-alias %q, want [frontend]", got)
+	}
+	f.call("docker", "network", "connect", "--alias", "api", "--alias", "api.internal", "backend", "web")
+	f.call("docker", "network", "connect", "metrics", "web")
+	if run, connect := f.index("docker", "run"), f.index("docker", "network", "connect"); connect < run {
+		t.Errorf("networks connected before docker run; calls:\n%s", f.dump())
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"sync"
+)
+
+// prePullKey identifies a pull: the same image for two platforms is two
+// pulls.
+func prePullKey(image, platform string) string {
+	if platform == "" {
+		return image
+	}
+	return image + "@" + platform
+}
+
+// prePullImages pulls the images of all containers of a batch before any of
+// them is recreated, with at most limit pulls at a time. Pulls are network
+// bound and can run much wider than recreates, which stay one at a time.
+//
+// Each distinct image is pulled once. A pull that fails is only reported
+// here; the container's own recreate then pulls it again and fails the
+// usual way, so nothing is stopped over an image that could not be fetched.
+func prePullImages(containers []string, limit int) map[string]bool {
+	type pull struct{ image, platform string }
+	var pulls []pull
+	seen := make(map[string]bool)
+	for _, name := range containers {
+		info, err := getContainerInfo(name)
+		if err != nil {
+			// Resumed and missing containers are left to processContainer.
+			continue
+		}
+		image, platform := targetImage(info), emulatedPlatform(info)
+		if key := prePullKey(image, platform); !seen[key] {
+			seen[key] = true
+			pulls = append(pulls, pull{image, platform})
+		}
+	}
+	if len(pulls) == 0 {
+		return nil
+	}
+	if opts.minFreeSpace > 0 {
+		if err := checkFreeSpace(int64(opts.minFreeSpace)); err != nil {
+			printWarning("Skipping the pre-pull: %v\n", err)
+			return nil
+		}
+	}
+
+	printInfo("Pre-pulling %d image(s), %d at a time...\n", len(pulls), min(limit, len(pulls)))
+	var (
+		mu     sync.Mutex
+		wg     sync.WaitGroup
+		pulled = make(map[string]bool)
+		sem    = make(chan struct{}, limit)
+	)
+	for _, p := range pulls {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			var out bytes.Buffer
+			err := pullImage(p.image, p.platform, &out, &out)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				printWarning("Pre-pull of %s failed, retrying during its recreate: %v\n", p.image, err)
+				os.Stderr.Write(out.Bytes())
+				return
+			}
+			printSuccess("Pulled %s\n", p.image)
+			pulled[prePullKey(p.image, p.platform)] = true
+		}()
+	}
+	wg.Wait()
+	return pulled
+}
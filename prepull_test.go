@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPullConcurrency(t *testing.T) {
+	f, _ := setupFlow(t)
+	var names []string
+	for i := 1; i <= 5; i++ {
+		name := fmt.Sprintf("app%d", i)
+		names = append(names, name)
+		f.inspect(name, fmt.Sprintf(`{"Id":"%s0000000000","Name":"/%s","Image":"sha256:old","Config":{"Image":"registry.example/%s:1"},"HostConfig":{},"State":{"Running":true}}`, name, name, name))
+	}
+	f.on(fakeRule{Args: []string{"docker", "pull"}, Sleep: 300 * time.Millisecond})
+	opts.pullConcurrency = 2
+
+	if code := runUpdate(names); code != 0 {
+		t.Fatalf("exit code = %d; calls:\n%s", code, f.dump())
+	}
+	if n := f.count("docker", "pull"); n != len(names) {
+		t.Errorf("%d pulls, want one per image (%d)", n, len(names))
+	}
+	if peak := f.maxConcurrent("docker", "pull"); peak != 2 {
+		t.Errorf("%d pulls at once, want --pull-concurrency 2", peak)
+	}
+	if peak := f.maxConcurrent("docker", "run"); peak != 1 {
+		t.Errorf("%d recreates at once, want one at a time", peak)
+	}
+	if first, lastPull := f.index("docker", "stop"), lastIndex(f, "docker", "pull"); first < lastPull {
+		t.Errorf("a container was stopped before every image was pulled; calls:\n%s", f.dump())
+	}
+}
+
+// lastIndex returns the position of the last call matching args, or -1.
+func lastIndex(f *fakeDocker, args ...string) int {
+	last := -1
+	for i, argv := range f.calls() {
+		if (fakeRule{Args: args}).matches(argv) {
+			last = i
+		}
+	}
+	return last
+}
+
+func TestInspectCache(t *testing.T) {
+	for _, tt := range []struct {
+		cache bool
+		want  int
+	}{{true, 1}, {false, 2}} {
+		t.Run(fmt.Sprintf("inspect-cache=%v", tt.cache), func(t *testing.T) {
+			f, _ := setupFlow(t)
+			names := []string{"web", "worker"}
+			for _, name := range names {
+				f.inspect(name, fmt.Sprintf(`{"Id":"%s0000000000","Name":"/%s","Image":"sha256:old","Config":{"Image":"registry.example/%s:1"},"HostConfig":{},"State":{"Running":true}}`, name, name, name))
+			}
+			opts.pullConcurrency, opts.strict, opts.inspectCache = 2, true, tt.cache
+
+			if code := runUpdate(names); code != 0 {
+				t.Fatalf("exit code = %d; calls:\n%s", code, f.dump())
+			}
+			for _, name := range names {
+				if n := f.count("docker", "inspect", name); n != tt.want {
+					t.Errorf("%s inspected %d times, want %d; calls:\n%s", name, n, tt.want, f.dump())
+				}
+			}
+		})
+	}
+}
+
+func TestPullBudgetExhausted(t *testing.T) {
+	tests := []struct {
+		name string
+		rule fakeRule
+	}{
+		{"failing pulls", fakeRule{Args: []string{"docker", "pull"}, Stderr: "toomanyrequests\n", Exit: 1}},
+		{"slow pull", fakeRule{Args: []string{"docker", "pull"}, Sleep: 10 * time.Second}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, _ := setupFlow(t)
+			f.inspect("web", fixture(t, "many-flags"))
+			f.on(tt.rule)
+			opts.pullRetries, opts.pullBudget = 5, 500*time.Millisecond
+
+			start := time.Now()
+			result := processOne("web")
+			if result.Status != statusFailed || !strings.Contains(result.Error, "--pull-budget 500ms runs out") {
+				t.Fatalf("status = %s (%s), want a failure naming --pull-budget", result.Status, result.Error)
+			}
+			if elapsed := time.Since(start); elapsed > 5*time.Second {
+				t.Errorf("pull took %s, want it cut off by the budget", elapsed)
+			}
+			if n := f.count("docker", "pull"); n != 1 {
+				t.Errorf("%d pull attempts, want the budget to stop retrying before --pull-retries does", n)
+			}
+			if f.index("docker", "stop") >= 0 {
+				t.Errorf("container stopped after the pull failed; calls:\n%s", f.dump())
+			}
+		})
+	}
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// remoteDigest resolves the manifest digest an image reference currently
+// points to in its registry, without pulling it. It relies on docker buildx;
+// podman has no command that prints the digest of a registry manifest.
+func remoteDigest(imageRef string) (string, error) {
+	if isPodman() {
+		return "", fmt.Errorf("resolving the registry digest of %s needs docker buildx, which is not supported with --runtime podman", imageRef)
+	}
+	output, err := dockerCommand("buildx", "imagetools", "inspect", "--format", "{{json .Manifest}}", imageRef).Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve registry digest for %s: %v", imageRef, err)
+	}
+
+	var manifest struct {
+		Digest string `json:"digest"`
+	}
+	if err := json.Unmarshal(output, &manifest); err != nil {
+		return "", fmt.Errorf("failed to parse registry manifest for %s: %v", imageRef, err)
+	}
+	if manifest.Digest == "" {
+		return "", fmt.Errorf("registry returned no digest for %s", imageRef)
+	}
+	return manifest.Digest, nil
+}
+
+// localRepoDigests returns the repo digests (repo@sha256:...) recorded for
+// a local image ID.
+func localRepoDigests(imageID string) ([]string, error) {
+	output, err := dockerCommand("image", "inspect", "--format", "{{json .RepoDigests}}", imageID).Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect image %s: %v", imageID, err)
+	}
+
+	var digests []string
+	if err := json.Unmarshal(output, &digests); err != nil {
+		return nil, fmt.Errorf("failed to parse repo digests for %s: %v", imageID, err)
+	}
+	return digests, nil
+}
+
+// imageFreshness reports whether the image a container runs is still the one
+// its tag resolves to in the registry. remote is the registry digest.
+func imageFreshness(info *ContainerInfo) (current bool, remote string, err error) {
+	remote, err = remoteDigest(info.Config.Image)
+	if err != nil {
+		return false, "", err
+	}
+
+	digests, err := localRepoDigests(info.ImageID)
+	if err != nil {
+		return false, remote, err
+	}
+	for _, digest := range digests {
+		if strings.HasSuffix(digest, "@"+remote) {
+			return true, remote, nil
+		}
+	}
+	return false, remote, nil
+}
+
+// localImageID returns the ID of the local image a reference resolves to.
+func localImageID(imageRef string) (string, error) {
+	output, err := dockerCommand("image", "inspect", "--format", "{{.Id}}", imageRef).Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to inspect image %s: %v", imageRef, err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// digestChanged resolves the registry digest of imageRef and reports whether
+// it differs from the digest recorded by the last drun run for the
+// container. Without a recorded digest the running image's repo digests are
+// used, so a tag that was never moved does not trigger a recreate.
+func digestChanged(info *ContainerInfo, imageRef string) (digest string, changed bool, err error) {
+	digest, err = remoteDigest(imageRef)
+	if err != nil {
+		return "", false, err
+	}
+
+	state, err := loadState(opts.stateFile)
+	if err != nil {
+		return digest, false, fmt.Errorf("failed to read state file: %v", err)
+	}
+	if entry, ok := state[strings.TrimPrefix(info.Name, "/")]; ok && entry.Digest != "" {
+		return digest, entry.Digest != digest, nil
+	}
+
+	digests, err := localRepoDigests(info.ImageID)
+	if err != nil {
+		return digest, false, err
+	}
+	for _, local := range digests {
+		if strings.HasSuffix(local, "@"+digest) {
+			return digest, false, nil
+		}
+	}
+	return digest, true, nil
+}
+
+// imagePlatform returns the os/arch[/variant] a local image was built for.
+func imagePlatform(imageID string) (string, error) {
+	output, err := dockerCommand("image", "inspect", "--format", "{{.Os}}/{{.Architecture}}{{if .Variant}}/{{.Variant}}{{end}}", imageID).Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to inspect image %s: %v", imageID, err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+var daemonPlatformCache string
+
+// daemonPlatform returns the daemon's native os/arch, e.g. linux/arm64.
+func daemonPlatform() (string, error) {
+	if daemonPlatformCache != "" {
+		return daemonPlatformCache, nil
+	}
+	output, err := dockerCommand("version", "--format", "{{.Server.Os}}/{{.Server.Arch}}").Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to query daemon platform: %v", err)
+	}
+	daemonPlatformCache = strings.TrimSpace(string(output))
+	return daemonPlatformCache, nil
+}
+
+// emulatedPlatform returns the platform of the container's image when it
+// differs from the daemon's native one, e.g. an amd64 image running under
+// emulation on an arm64 host. Pulling without --platform would silently
+// switch such a container to the native variant.
+func emulatedPlatform(info *ContainerInfo) string {
+	platform, err := imagePlatform(info.ImageID)
+	if err != nil {
+		printWarning("Could not determine image platform: %v\n", err)
+		return ""
+	}
+	native, err := daemonPlatform()
+	if err != nil {
+		printWarning("Could not determine daemon platform: %v\n", err)
+		return ""
+	}
+	// A variant-less native platform ("linux/arm64") matches "linux/arm64/v8".
+	if platform == native || strings.HasPrefix(platform, native+"/") {
+		return ""
+	}
+	return platform
+}
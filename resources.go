@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// defaultCPUPeriod is the CFS period docker uses when only a quota is set.
+const defaultCPUPeriod = 100000
+
+// resourceArgs renders the CPU and memory limits of a container. Docker refuses --cpus
+// together with --cpu-quota/--cpu-period, so only one form is ever emitted.
+// A quota/period pair is turned into the equivalent --cpus value unless
+// --prefer-raw-cpu asks for the pair, or the ratio has more precision than
+// --cpus can carry.
+func resourceArgs(info *ContainerInfo) []string {
+	var args []string
+	hc := info.HostConfig
+
+	switch {
+	case hc.NanoCpus > 0:
+		if hc.CpuQuota > 0 || hc.CpuPeriod > 0 {
+			printWarning("Container has both NanoCpus and CpuQuota/CpuPeriod set; keeping --cpus %s and dropping the quota/period pair\n", formatCPUs(hc.NanoCpus))
+		}
+		args = append(args, "--cpus", formatCPUs(hc.NanoCpus))
+	case hc.CpuQuota > 0 && !opts.preferRawCPU:
+		period := hc.CpuPeriod
+		if period <= 0 {
+			period = defaultCPUPeriod
+		}
+		if nanoCPUs, exact := quotaToNanoCPUs(hc.CpuQuota, period); exact {
+			args = append(args, "--cpus", formatCPUs(nanoCPUs))
+			break
+		}
+		args = append(args, "--cpu-quota", fmt.Sprintf("%d", hc.CpuQuota))
+		if hc.CpuPeriod > 0 {
+			args = append(args, "--cpu-period", fmt.Sprintf("%d", hc.CpuPeriod))
+		}
+	default:
+		if hc.CpuQuota > 0 {
+			args = append(args, "--cpu-quota", fmt.Sprintf("%d", hc.CpuQuota))
+		}
+		if hc.CpuPeriod > 0 {
+			args = append(args, "--cpu-period", fmt.Sprintf("%d", hc.CpuPeriod))
+		}
+	}
+
+	if hc.CpuShares > 0 {
+		args = append(args, "--cpu-shares", fmt.Sprintf("%d", hc.CpuShares))
+	}
+
+	if hc.Memory > 0 {
+		args = append(args, "--memory", formatSize(hc.Memory))
+		if swap, ok := memorySwapFlag(hc.Memory, hc.MemorySwap); ok {
+			args = append(args, "--memory-swap", swap)
+		}
+	}
+	return args
+}
+
+// memorySwapFlag renders MemorySwap, where -1 means unlimited swap and a
+// missing or zero value means none was set. Docker stores twice the memory
+// limit when only --memory is given; that default is left implicit. Docker
+// refuses --memory-swap without --memory, so callers only ask with a limit.
+func memorySwapFlag(memory int64, swap *int64) (string, bool) {
+	switch {
+	case swap == nil || *swap == 0:
+		return "", false
+	case *swap == -1:
+		return "-1", true
+	case *swap == 2*memory:
+		return "", false
+	}
+	return formatSize(*swap), true
+}
+
+// formatCPUs turns NanoCpus back into the decimal --cpus value, e.g.
+// 1500000000 into "1.5". It uses integer arithmetic so no float rounding
+// noise ends up in the command.
+func formatCPUs(nanoCPUs int64) string {
+	whole, frac := nanoCPUs/1e9, nanoCPUs%1e9
+	if frac == 0 {
+		return fmt.Sprintf("%d", whole)
+	}
+	return strings.TrimRight(fmt.Sprintf("%d.%09d", whole, frac), "0")
+}
+
+// quotaToNanoCPUs converts a CFS quota/period pair into NanoCpus, e.g.
+// 150000/100000 into 1.5 CPUs. exact is false when the ratio does not come
+// out to a whole number of nano CPUs.
+func quotaToNanoCPUs(quota, period int64) (nanoCPUs int64, exact bool) {
+	return quota * 1e9 / period, quota*1e9%period == 0
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestResourceArgsCPUConflict(t *testing.T) {
+	out := resetState(t)
+	info := testContainer(t, "", `"NanoCpus":2000000000,"CpuQuota":50000,"CpuPeriod":100000,"CpuShares":256`)
+
+	got := resourceArgs(info)
+	if want := []string{"--cpus", "2", "--cpu-shares", "256"}; !slices.Equal(got, want) {
+		t.Errorf("resourceArgs = %q, want %q", got, want)
+	}
+	if !strings.Contains(out.String(), "both NanoCpus and CpuQuota") {
+		t.Errorf("no warning about the dropped quota/period pair:\n%s", out)
+	}
+	for _, flag := range []string{"--cpu-quota", "--cpu-period"} {
+		if slices.Contains(buildRunArgs(info), flag) {
+			t.Errorf("run command has %s next to --cpus", flag)
+		}
+	}
+}
+
+func TestResourceArgsQuotaPeriod(t *testing.T) {
+	tests := []struct {
+		name         string
+		hostConfig   string
+		preferRawCPU bool
+		want         []string
+	}{
+		{"derived --cpus", `"CpuQuota":150000,"CpuPeriod":100000`, false, []string{"--cpus", "1.5"}},
+		{"default period", `"CpuQuota":50000`, false, []string{"--cpus", "0.5"}},
+		{"--prefer-raw-cpu", `"CpuQuota":150000,"CpuPeriod":100000`, true, []string{"--cpu-quota", "150000", "--cpu-period", "100000"}},
+		{"inexact ratio", `"CpuQuota":1,"CpuPeriod":3`, false, []string{"--cpu-quota", "1", "--cpu-period", "3"}},
+		{"period alone", `"CpuPeriod":50000`, false, []string{"--cpu-period", "50000"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetState(t)
+			opts.preferRawCPU = tt.preferRawCPU
+			if got := resourceArgs(testContainer(t, "", tt.hostConfig)); !slices.Equal(got, tt.want) {
+				t.Errorf("resourceArgs = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMemorySwap(t *testing.T) {
+	tests := []struct {
+		name       string
+		hostConfig string
+		want       []string
+	}{
+		{"unlimited", `"Memory":536870912,"MemorySwap":-1`, []string{"--memory", "512m", "--memory-swap", "-1"}},
+		{"specific", `"Memory":536870912,"MemorySwap":2147483648`, []string{"--memory", "512m", "--memory-swap", "2g"}},
+		{"docker's default of twice the memory", `"Memory":536870912,"MemorySwap":1073741824`, []string{"--memory", "512m"}},
+		{"unset", `"Memory":536870912`, []string{"--memory", "512m"}},
+		{"zero", `"Memory":536870912,"MemorySwap":0`, []string{"--memory", "512m"}},
+		{"without a memory limit", `"MemorySwap":-1`, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetState(t)
+			if got := resourceArgs(testContainer(t, "", tt.hostConfig)); !slices.Equal(got, tt.want) {
+				t.Errorf("resourceArgs = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
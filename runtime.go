@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// runtimeEnv overrides the default runtime for hosts that only have podman,
+// so that not every invocation needs --runtime.
+const runtimeEnv = "DRUN_RUNTIME"
+
+// defaultRuntime is the runtime used when --runtime is not given.
+func defaultRuntime() string {
+	if runtime := os.Getenv(runtimeEnv); runtime != "" {
+		return runtime
+	}
+	return "docker"
+}
+
+// runtimeBinary is the container CLI drun drives. Podman's CLI and inspect
+// output are close enough to docker's for the same flow to work.
+// Subcommands that never register --runtime fall back to defaultRuntime.
+func runtimeBinary() string {
+	if opts.runtime != "" {
+		return opts.runtime
+	}
+	return defaultRuntime()
+}
+
+// addRuntimeFlags registers the options selecting the runtime and the daemon
+// on the update flow and on every subcommand that talks to the daemon.
+func addRuntimeFlags(fs *flag.FlagSet) {
+	fs.StringVar(&opts.host, "host", "", "daemon `URL` to operate on, e.g. ssh://deploy@host1 (default: $DOCKER_HOST or the local daemon)")
+	fs.StringVar(&opts.host, "H", "", "shorthand for --host")
+	fs.StringVar(&opts.runtime, "runtime", defaultRuntime(), "container runtime `BINARY` to drive (docker or podman; default from $DRUN_RUNTIME)")
+}
+
+// splitRuntimeFlags splits off the runtime flags given ahead of a
+// subcommand, as in `drun -H ssh://host1 list`, from the rest of the
+// command line.
+func splitRuntimeFlags(args []string) (global, rest []string) {
+	for len(args) > 0 && strings.HasPrefix(args[0], "-") {
+		name, _, inline := strings.Cut(strings.TrimLeft(args[0], "-"), "=")
+		if name != "host" && name != "H" && name != "runtime" {
+			break
+		}
+		n := 2
+		if inline || len(args) == 1 {
+			n = 1
+		}
+		global, args = append(global, args[:n]...), args[n:]
+	}
+	return global, args
+}
+
+func isPodman() bool {
+	return filepath.Base(runtimeBinary()) == "podman"
+}
+
+// hostArgs selects the daemon given with -H/--host. Podman calls the same
+// option --url. Without -H the runtime falls back to DOCKER_HOST (or
+// CONTAINER_HOST for podman) from the environment on its own.
+func hostArgs() []string {
+	switch {
+	case opts.host == "":
+		return nil
+	case isPodman():
+		return []string{"--url", opts.host}
+	}
+	return []string{"--host", opts.host}
+}
+
+// remoteDaemon returns the daemon address when drun drives a daemon on
+// another machine, whose files are not on this one, and "" for a local one.
+func remoteDaemon() string {
+	host := opts.host
+	if host == "" && isPodman() {
+		host = os.Getenv("CONTAINER_HOST")
+	}
+	if host == "" {
+		host = os.Getenv("DOCKER_HOST")
+	}
+	if host == "" || strings.HasPrefix(host, "unix://") {
+		return ""
+	}
+	return host
+}
+
+// runtimeCommand is the runtime binary plus its global options, the prefix
+// of every command drun runs or prints.
+func runtimeCommand(args ...string) []string {
+	return append(append([]string{runtimeBinary()}, hostArgs()...), args...)
+}
+
+func dockerCommand(args ...string) *exec.Cmd {
+	argv := runtimeCommand(args...)
+	return exec.Command(argv[0], argv[1:]...)
+}
+
+func dockerCommandContext(ctx context.Context, args ...string) *exec.Cmd {
+	argv := runtimeCommand(args...)
+	return exec.CommandContext(ctx, argv[0], argv[1:]...)
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestSplitRuntimeFlags(t *testing.T) {
+	tests := []struct {
+		args         []string
+		global, rest []string
+	}{
+		{[]string{"list"}, nil, []string{"list"}},
+		{[]string{"-H", "ssh://h", "list", "--quiet"}, []string{"-H", "ssh://h"}, []string{"list", "--quiet"}},
+		{[]string{"--host=ssh://h", "--runtime", "podman", "check", "web"}, []string{"--host=ssh://h", "--runtime", "podman"}, []string{"check", "web"}},
+		{[]string{"-runtime=podman", "web"}, []string{"-runtime=podman"}, []string{"web"}},
+		// Other flags belong to the update flow and end the runtime flags.
+		{[]string{"-H", "ssh://h", "--yes", "list"}, []string{"-H", "ssh://h"}, []string{"--yes", "list"}},
+		{[]string{"--hostname", "x"}, nil, []string{"--hostname", "x"}},
+		{[]string{"-H"}, []string{"-H"}, nil},
+	}
+	for _, tt := range tests {
+		global, rest := splitRuntimeFlags(tt.args)
+		if !slices.Equal(global, tt.global) || !slices.Equal(rest, tt.rest) {
+			t.Errorf("splitRuntimeFlags(%q) = %q, %q, want %q, %q", tt.args, global, rest, tt.global, tt.rest)
+		}
+	}
+}
+
+func TestSubcommandRuntimeFlags(t *testing.T) {
+	const inspect = `[{"Id":"web1","Name":"/web","Image":"sha256:web","Config":{"Image":"nginx:1.25"},"HostConfig":{}}]`
+	tests := []struct {
+		name    string
+		args    []string
+		runtime []string // what every call starts with
+		call    []string
+	}{
+		{"global before list", []string{"-H", "ssh://h", "list", "--quiet"}, []string{"docker", "--host", "ssh://h"}, []string{"ps"}},
+		{"list", []string{"list", "--host", "ssh://h", "--quiet"}, []string{"docker", "--host", "ssh://h"}, []string{"ps"}},
+		{"check", []string{"check", "--runtime", "podman", "-H", "ssh://h", "web"}, []string{"podman", "--url", "ssh://h"}, []string{"inspect", "web"}},
+		{"global before check", []string{"--runtime=podman", "check", "web"}, []string{"podman"}, []string{"inspect", "web"}},
+		{"diff-image", []string{"-H", "ssh://h", "diff-image", "web"}, []string{"docker", "--host", "ssh://h"}, []string{"inspect", "web"}},
+		{"__complete", []string{"__complete", "--runtime", "podman"}, []string{"podman"}, []string{"ps"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetState(t)
+			f := newFakeDocker(t)
+			f.answer("web\n", slices.Concat(tt.runtime, []string{"ps"})...)
+			f.answer(inspect+"\n", slices.Concat(tt.runtime, []string{"inspect", "web"})...)
+
+			captureStdout(t, func() { run(tt.args) })
+			f.call(slices.Concat(tt.runtime, tt.call)...)
+			for _, argv := range f.calls() {
+				if !slices.Equal(argv[:min(len(argv), len(tt.runtime))], tt.runtime) {
+					t.Errorf("%q does not start with %q", argv, tt.runtime)
+				}
+			}
+		})
+	}
+}
+
+func TestRunCommandUsesRuntimeBinary(t *testing.T) {
+	tests := []struct {
+		args []string
+		want []string
+	}{
+		{[]string{"app"}, []string{"docker", "run", "-d"}},
+		{[]string{"--runtime", "podman", "app"}, []string{"podman", "run", "-d"}},
+		{[]string{"--runtime", "/usr/local/bin/podman", "-H", "ssh://h", "app"}, []string{"/usr/local/bin/podman", "--url", "ssh://h", "run", "-d"}},
+	}
+	for _, tt := range tests {
+		resetState(t)
+		if _, code := parseFlags(tt.args); code != 0 {
+			t.Fatalf("%q: exit code = %d", tt.args, code)
+		}
+		argv := generateRunCommand(testContainer(t, "", ""))
+		if !slices.Equal(argv[:len(tt.want)], tt.want) {
+			t.Errorf("%q: run command %q, want it to start with %q", tt.args, argv, tt.want)
+		}
+	}
+}
+
+func TestRemoteDigestPodman(t *testing.T) {
+	resetState(t)
+	f := newFakeDocker(t)
+	opts.runtime = "podman"
+
+	_, err := remoteDigest("nginx:1.25")
+	if err == nil || !strings.Contains(err.Error(), "not supported with --runtime podman") {
+		t.Fatalf("remoteDigest under podman = %v, want a clear unsupported error", err)
+	}
+	if n := f.count("podman"); n > 0 {
+		t.Errorf("ran podman %d times; calls:\n%s", n, f.dump())
+	}
+}
@@ -0,0 +1,35 @@
+package main
+
+import "strings"
+
+// isSecretEnvName reports whether an environment variable name looks like it
+// holds a credential: *_TOKEN, *_PASSWORD, *_KEY or anything with SECRET.
+func isSecretEnvName(name string) bool {
+	name = strings.ToUpper(name)
+	return strings.HasSuffix(name, "_TOKEN") ||
+		strings.HasSuffix(name, "_PASSWORD") ||
+		strings.HasSuffix(name, "_KEY") ||
+		strings.Contains(name, "SECRET")
+}
+
+// redactEnv masks the value of a KEY=VALUE entry whose name looks secret.
+func redactEnv(env string) string {
+	name, _, ok := strings.Cut(env, "=")
+	if ok && isSecretEnvName(name) {
+		return name + "=***"
+	}
+	return env
+}
+
+// maskCommandSecrets returns a copy of a docker argv with the values of
+// secret-looking -e variables replaced by ***, for display only.
+func maskCommandSecrets(args []string) []string {
+	masked := make([]string, len(args))
+	copy(masked, args)
+	for i := 1; i < len(masked); i++ {
+		if masked[i-1] == "-e" {
+			masked[i] = redactEnv(masked[i])
+		}
+	}
+	return masked
+}
@@ -0,0 +1,310 @@
+package main
+
+import (
+	"bufio"
+	"cmp"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// version is the release drun was built from, set at build time with
+// -ldflags "-X main.version=v1.2.3". Development builds report "dev" and
+// are only replaced with --force.
+var version = "dev"
+
+// releaseURL is the GitHub API endpoint describing the latest release.
+var releaseURL = "https://api.github.com/repos/abcdlsj/drun/releases/latest"
+
+// checksumsAsset is the release asset listing "<sha256>  <asset>" lines.
+const checksumsAsset = "checksums.txt"
+
+type release struct {
+	TagName string `json:"tag_name"`
+	Assets  []struct {
+		Name string `json:"name"`
+		URL  string `json:"browser_download_url"`
+	} `json:"assets"`
+}
+
+func (r *release) assetURL(name string) string {
+	for _, asset := range r.Assets {
+		if asset.Name == name {
+			return asset.URL
+		}
+	}
+	return ""
+}
+
+// releaseAssetName is the binary published for the running platform, e.g.
+// drun_linux_amd64 or drun_windows_amd64.exe.
+func releaseAssetName() string {
+	name := fmt.Sprintf("drun_%s_%s", runtime.GOOS, runtime.GOARCH)
+	if runtime.GOOS == "windows" {
+		name += ".exe"
+	}
+	return name
+}
+
+var httpClient = &http.Client{Timeout: 5 * time.Minute}
+
+// runSelfUpdate implements `drun self-update`: it downloads the latest
+// release binary for this platform, checks it against the release's
+// checksums and swaps it in, keeping the old binary next to it as .bak.
+// Only a newer release is installed; --force installs it regardless, e.g.
+// over a development build or to go back from a newer one.
+func runSelfUpdate(args []string) int {
+	fs := flag.NewFlagSet("drun self-update", flag.ExitOnError)
+	check := fs.Bool("check", false, "only report whether a newer release exists")
+	force := fs.Bool("force", false, "install the latest release even if it is not newer than the running version")
+	fs.Parse(args)
+
+	rel, err := latestRelease()
+	if err != nil {
+		printError("Failed to look up the latest release: %v\n", err)
+		return 1
+	}
+	latest, ok := parseVersion(rel.TagName)
+	if !ok {
+		printError("The latest release is tagged %q, which is not a version\n", rel.TagName)
+		return 1
+	}
+
+	current, known := parseVersion(version)
+	switch {
+	case !known:
+		printInfo("drun %s is available (running development build %s)\n", rel.TagName, version)
+		if !*force && !*check {
+			printWarning("Not replacing a development build; pass --force to install %s\n", rel.TagName)
+			return 0
+		}
+	case latest.compare(current) == 0:
+		printSuccess("drun %s is the latest release\n", version)
+		if !*force {
+			return 0
+		}
+	case latest.compare(current) < 0:
+		printWarning("drun %s is newer than the latest release %s; not downgrading\n", version, rel.TagName)
+		if !*force {
+			return 0
+		}
+	default:
+		printInfo("drun %s is available (running %s)\n", rel.TagName, version)
+	}
+	if *check {
+		return 0
+	}
+
+	exe, err := os.Executable()
+	if err == nil {
+		exe, err = filepath.EvalSymlinks(exe)
+	}
+	if err != nil {
+		printError("Failed to locate the running executable: %v\n", err)
+		return 1
+	}
+
+	if err := installRelease(rel, exe); err != nil {
+		printError("Self-update failed: %v\n", err)
+		return 1
+	}
+	printSuccess("Updated %s to %s (previous binary kept as %s.bak)\n", exe, rel.TagName, exe)
+	return 0
+}
+
+// semver is a parsed release version such as v1.2.3 or v1.3.0-rc.1.
+type semver struct {
+	major, minor, patch int
+	pre                 []string
+}
+
+// parseVersion parses a release tag, with or without the leading v. Build
+// metadata after + is ignored, as semver says it does not affect ordering.
+func parseVersion(tag string) (semver, bool) {
+	s, _, _ := strings.Cut(strings.TrimPrefix(tag, "v"), "+")
+	s, pre, hasPre := strings.Cut(s, "-")
+
+	parts := strings.Split(s, ".")
+	if len(parts) != 3 {
+		return semver{}, false
+	}
+	var nums [3]int
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return semver{}, false
+		}
+		nums[i] = n
+	}
+
+	v := semver{major: nums[0], minor: nums[1], patch: nums[2]}
+	if hasPre {
+		if pre == "" {
+			return semver{}, false
+		}
+		v.pre = strings.Split(pre, ".")
+	}
+	return v, true
+}
+
+// compare orders versions by semver precedence: -1 if v is older than w,
+// 0 if they are equal and 1 if v is newer. A pre-release sorts before the
+// release it leads up to.
+func (v semver) compare(w semver) int {
+	if c := cmp.Or(cmp.Compare(v.major, w.major), cmp.Compare(v.minor, w.minor), cmp.Compare(v.patch, w.patch)); c != 0 {
+		return c
+	}
+	switch {
+	case len(v.pre) == 0 && len(w.pre) == 0:
+		return 0
+	case len(v.pre) == 0:
+		return 1
+	case len(w.pre) == 0:
+		return -1
+	}
+	for i := 0; i < len(v.pre) && i < len(w.pre); i++ {
+		a, aErr := strconv.Atoi(v.pre[i])
+		b, bErr := strconv.Atoi(w.pre[i])
+		switch {
+		case aErr == nil && bErr == nil:
+			if a != b {
+				return cmp.Compare(a, b)
+			}
+		// Numeric identifiers sort before alphanumeric ones.
+		case aErr == nil:
+			return -1
+		case bErr == nil:
+			return 1
+		default:
+			if c := strings.Compare(v.pre[i], w.pre[i]); c != 0 {
+				return c
+			}
+		}
+	}
+	return cmp.Compare(len(v.pre), len(w.pre))
+}
+
+func latestRelease() (*release, error) {
+	resp, err := httpClient.Get(releaseURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s returned %s", releaseURL, resp.Status)
+	}
+
+	var rel release
+	if err := json.NewDecoder(resp.Body).Decode(&rel); err != nil {
+		return nil, fmt.Errorf("failed to parse release: %v", err)
+	}
+	if rel.TagName == "" {
+		return nil, fmt.Errorf("release has no tag")
+	}
+	return &rel, nil
+}
+
+// installRelease downloads the platform binary next to exe, verifies its
+// checksum and only then renames it over exe. Nothing is replaced unless
+// the download matches.
+func installRelease(rel *release, exe string) error {
+	name := releaseAssetName()
+	binaryURL := rel.assetURL(name)
+	if binaryURL == "" {
+		return fmt.Errorf("release %s has no binary %s", rel.TagName, name)
+	}
+	checksumsURL := rel.assetURL(checksumsAsset)
+	if checksumsURL == "" {
+		return fmt.Errorf("release %s has no %s, refusing to install an unverified binary", rel.TagName, checksumsAsset)
+	}
+
+	want, err := releaseChecksum(checksumsURL, name)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(exe), ".drun-update-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	got, err := download(binaryURL, tmp)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to download %s: %v", name, err)
+	}
+	if got != want {
+		return fmt.Errorf("checksum mismatch for %s: expected %s, got %s", name, want, got)
+	}
+
+	if err := os.Chmod(tmp.Name(), 0o755); err != nil {
+		return err
+	}
+	backup := exe + ".bak"
+	if err := os.Rename(exe, backup); err != nil {
+		return fmt.Errorf("failed to back up %s: %v", exe, err)
+	}
+	if err := os.Rename(tmp.Name(), exe); err != nil {
+		if restoreErr := os.Rename(backup, exe); restoreErr != nil {
+			return fmt.Errorf("failed to install new binary: %v (restoring the backup also failed: %v)", err, restoreErr)
+		}
+		return fmt.Errorf("failed to install new binary: %v", err)
+	}
+	return nil
+}
+
+// releaseChecksum fetches the checksums file and returns the sha256 listed
+// for the named asset.
+func releaseChecksum(url, name string) (string, error) {
+	resp, err := httpClient.Get(url)
+	if err != nil {
+		return "", fmt.Errorf("failed to download %s: %v", checksumsAsset, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to download %s: %s", checksumsAsset, resp.Status)
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && strings.TrimPrefix(fields[1], "*") == name {
+			return strings.ToLower(fields[0]), nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", fmt.Errorf("failed to read %s: %v", checksumsAsset, err)
+	}
+	return "", fmt.Errorf("%s has no entry for %s", checksumsAsset, name)
+}
+
+// download writes the body at url to w and returns its hex sha256.
+func download(url string, w io.Writer) (string, error) {
+	resp, err := httpClient.Get(url)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%s returned %s", url, resp.Status)
+	}
+
+	hash := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(w, hash), resp.Body); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"v1.2.3", "v1.2.3", 0},
+		{"v1.2.3", "1.2.3", 0},
+		{"v1.2.3+build.5", "v1.2.3", 0},
+		{"v1.2.4", "v1.2.3", 1},
+		{"v1.10.0", "v1.9.9", 1},
+		{"v2.0.0", "v1.99.99", 1},
+		{"v1.3.0-rc.1", "v1.3.0", -1},
+		{"v1.3.0-rc.2", "v1.3.0-rc.10", -1},
+		{"v1.3.0-alpha", "v1.3.0-beta", -1},
+		{"v1.3.0-1", "v1.3.0-alpha", -1},
+		{"v1.3.0-rc", "v1.3.0-rc.1", -1},
+		{"v1.3.0-rc.1", "v1.2.9", 1},
+	}
+	for _, tt := range tests {
+		a, okA := parseVersion(tt.a)
+		b, okB := parseVersion(tt.b)
+		if !okA || !okB {
+			t.Fatalf("parseVersion(%q, %q) failed", tt.a, tt.b)
+		}
+		if got := a.compare(b); got != tt.want {
+			t.Errorf("compare(%s, %s) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+		if got := b.compare(a); got != -tt.want {
+			t.Errorf("compare(%s, %s) = %d, want %d", tt.b, tt.a, got, -tt.want)
+		}
+	}
+
+	for _, tag := range []string{"dev", "", "v1.2", "v1.2.x", "v1.2.3-", "v1.-2.3"} {
+		if _, ok := parseVersion(tag); ok {
+			t.Errorf("parseVersion(%q) accepted a non-version", tag)
+		}
+	}
+}
+
+// fakeRelease serves a release tagged tag with a binary and its checksums,
+// and counts the binary downloads.
+type fakeRelease struct {
+	server    *httptest.Server
+	binary    string
+	checksum  string
+	downloads atomic.Int32
+}
+
+func newFakeRelease(t *testing.T, tag, binary string) *fakeRelease {
+	t.Helper()
+	sum := sha256.Sum256([]byte(binary))
+	r := &fakeRelease{binary: binary, checksum: hex.EncodeToString(sum[:])}
+	mux := http.NewServeMux()
+	r.server = httptest.NewServer(mux)
+	t.Cleanup(r.server.Close)
+
+	mux.HandleFunc("/latest", func(w http.ResponseWriter, _ *http.Request) {
+		rel := map[string]any{
+			"tag_name": tag,
+			"assets": []map[string]string{
+				{"name": releaseAssetName(), "browser_download_url": r.server.URL + "/binary"},
+				{"name": checksumsAsset, "browser_download_url": r.server.URL + "/checksums"},
+			},
+		}
+		json.NewEncoder(w).Encode(rel)
+	})
+	mux.HandleFunc("/binary", func(w http.ResponseWriter, _ *http.Request) {
+		r.downloads.Add(1)
+		fmt.Fprint(w, r.binary)
+	})
+	mux.HandleFunc("/checksums", func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprintf(w, "%s  drun_other_platform\n%s  %s\n", strings.Repeat("0", 64), r.checksum, releaseAssetName())
+	})
+
+	savedURL, savedVersion := releaseURL, version
+	t.Cleanup(func() { releaseURL, version = savedURL, savedVersion })
+	releaseURL = r.server.URL + "/latest"
+	return r
+}
+
+func TestSelfUpdateOnlyUpgrades(t *testing.T) {
+	tests := []struct {
+		running string
+		args    []string
+		want    string
+	}{
+		{"v1.2.0", []string{"--check"}, "drun v1.3.0 is available (running v1.2.0)"},
+		{"v1.3.0", nil, "drun v1.3.0 is the latest release"},
+		{"v1.4.0", nil, "drun v1.4.0 is newer than the latest release v1.3.0; not downgrading"},
+		{"v1.3.0-rc.1", []string{"--check"}, "drun v1.3.0 is available (running v1.3.0-rc.1)"},
+		{"dev", nil, "Not replacing a development build; pass --force to install v1.3.0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.running, func(t *testing.T) {
+			out := resetState(t)
+			r := newFakeRelease(t, "v1.3.0", "new drun")
+			version = tt.running
+
+			if code := runSelfUpdate(tt.args); code != 0 {
+				t.Errorf("exit code = %d", code)
+			}
+			if !strings.Contains(out.String(), tt.want) {
+				t.Errorf("output has no %q:\n%s", tt.want, out)
+			}
+			if n := r.downloads.Load(); n != 0 {
+				t.Errorf("binary downloaded %d times", n)
+			}
+		})
+	}
+}
+
+func TestInstallRelease(t *testing.T) {
+	install := func(t *testing.T, r *fakeRelease) (string, error) {
+		t.Helper()
+		exe := filepath.Join(t.TempDir(), "drun")
+		if err := os.WriteFile(exe, []byte("old drun"), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		rel, err := latestRelease()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return exe, installRelease(rel, exe)
+	}
+
+	t.Run("verified", func(t *testing.T) {
+		resetState(t)
+		exe, err := install(t, newFakeRelease(t, "v1.3.0", "new drun"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for path, want := range map[string]string{exe: "new drun", exe + ".bak": "old drun"} {
+			if data, err := os.ReadFile(path); err != nil || string(data) != want {
+				t.Errorf("%s = %q, %v, want %q", filepath.Base(path), data, err, want)
+			}
+		}
+	})
+
+	t.Run("checksum mismatch", func(t *testing.T) {
+		resetState(t)
+		r := newFakeRelease(t, "v1.3.0", "new drun")
+		r.binary = "tampered drun"
+		exe, err := install(t, r)
+		if err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+			t.Fatalf("err = %v, want a checksum mismatch", err)
+		}
+		if data, _ := os.ReadFile(exe); string(data) != "old drun" {
+			t.Errorf("binary replaced with %q despite the mismatch", data)
+		}
+		if entries, _ := os.ReadDir(filepath.Dir(exe)); len(entries) != 1 {
+			t.Errorf("left files next to the binary: %v", entries)
+		}
+	})
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// defaultVerifierCmd is the --verifier-cmd used by --verify-signature.
+const defaultVerifierCmd = "cosign verify {image}"
+
+// pinnedReference returns imageRef pinned to the repo digest of the pulled
+// image ID, e.g. nginx@sha256:..., so the verifier checks exactly the image
+// that is about to run rather than whatever the tag points to by then.
+func pinnedReference(imageRef, imageID string) (string, error) {
+	digests, err := localRepoDigests(imageID)
+	if err != nil {
+		return "", err
+	}
+	ref := parseImageRef(imageRef)
+	for _, local := range digests {
+		repo, digest, ok := strings.Cut(local, "@")
+		if ok && sameRepository(parseImageRef(repo), ref) {
+			ref.Tag, ref.Digest = "", digest
+			return ref.String(), nil
+		}
+	}
+	return "", fmt.Errorf("image %s has no repo digest for %s to verify", shortID(imageID), imageRef)
+}
+
+// sameRepository compares two references by registry and path, treating
+// the spellings of Docker Hub and its library/ prefix as equal.
+func sameRepository(a, b imageRef) bool {
+	normalize := func(r imageRef) string {
+		if r.isDockerHub() {
+			return "docker.io/" + strings.TrimPrefix(r.Path, "library/")
+		}
+		return r.Domain + "/" + r.Path
+	}
+	return normalize(a) == normalize(b)
+}
+
+// verifySignature runs the configured verifier against an image and fails
+// when it exits non-zero. The command is split on whitespace and {image} is
+// replaced in every argument, e.g. "docker trust inspect {image}"; without
+// a placeholder the image is appended as the last argument.
+func verifySignature(image string) error {
+	fields := strings.Fields(opts.verifierCmd)
+	if len(fields) == 0 {
+		return fmt.Errorf("--verifier-cmd is empty")
+	}
+	for i, field := range fields {
+		fields[i] = strings.ReplaceAll(field, "{image}", image)
+	}
+	if !strings.Contains(opts.verifierCmd, "{image}") {
+		fields = append(fields, image)
+	}
+
+	cmd := exec.Command(fields[0], fields[1:]...)
+	cmd.Stdout = commandOutput
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s: %v", formatCommand(fields), err)
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestVerifySignature(t *testing.T) {
+	t.Run("passing", func(t *testing.T) {
+		f, _ := setupFlow(t)
+		f.inspect("web", fixture(t, "many-flags"))
+		f.answer(`["mirror.internal/library/nginx@sha256:m1","nginx@sha256:d1"]`, "docker", "image", "inspect", "--format", "{{json .RepoDigests}}", "sha256:new")
+		f.answer("Verification for nginx@sha256:d1 --", "cosign", "verify", "nginx@sha256:d1")
+		opts.verifySignature = true
+
+		if result := processOne("web"); result.Status != statusRestarted {
+			t.Fatalf("status = %s (%s), want %s", result.Status, result.Error, statusRestarted)
+		}
+		// The pulled image is verified by digest, not by the tag that
+		// could have moved since the pull.
+		pull, verify, stop := f.index("docker", "pull"), f.index("cosign", "verify", "nginx@sha256:d1"), f.index("docker", "stop")
+		if verify < 0 || !(pull < verify && verify < stop) {
+			t.Errorf("want pull, verify, stop in that order; calls:\n%s", f.dump())
+		}
+	})
+
+	t.Run("failing", func(t *testing.T) {
+		f, _ := setupFlow(t)
+		f.inspect("web", fixture(t, "many-flags"))
+		f.answer(`["docker.io/library/nginx@sha256:d1"]`, "docker", "image", "inspect", "--format", "{{json .RepoDigests}}", "sha256:new")
+		f.fail("Error: no matching signatures", "cosign", "verify")
+		opts.verifySignature = true
+
+		result := processOne("web")
+		if result.Status != statusFailed || !strings.Contains(result.Error, "cosign verify nginx@sha256:d1") {
+			t.Fatalf("result = %s %q, want the verification to fail", result.Status, result.Error)
+		}
+		if f.count("docker", "stop") > 0 || f.count("docker", "run") > 0 {
+			t.Errorf("the old container was touched after a failed verification; calls:\n%s", f.dump())
+		}
+	})
+
+	t.Run("no repo digest", func(t *testing.T) {
+		f, _ := setupFlow(t)
+		f.inspect("web", fixture(t, "many-flags"))
+		f.answer(`[]`, "docker", "image", "inspect", "--format", "{{json .RepoDigests}}", "sha256:new")
+		opts.verifySignature = true
+
+		result := processOne("web")
+		if result.Status != statusFailed || !strings.Contains(result.Error, "no repo digest for nginx:1.25") {
+			t.Fatalf("result = %s %q, want the verification to fail", result.Status, result.Error)
+		}
+		if f.count("cosign") > 0 || f.count("docker", "stop") > 0 {
+			t.Errorf("verified by tag or touched the old container; calls:\n%s", f.dump())
+		}
+	})
+
+	t.Run("custom verifier", func(t *testing.T) {
+		resetState(t)
+		f := newFakeDocker(t)
+		f.answer("", "docker", "trust", "inspect")
+		opts.verifierCmd = "docker trust inspect --pretty"
+
+		if err := verifySignature("nginx:1.25"); err != nil {
+			t.Fatal(err)
+		}
+		f.call("docker", "trust", "inspect", "--pretty", "nginx:1.25")
+	})
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// runSmokeTest runs the --smoke-test command against the new container:
+// inside it with docker exec, or on the host with --smoke-test-on-host.
+// Either way the command goes through sh -c so it can use pipes and quoting.
+func runSmokeTest(containerName string) error {
+	var cmd *exec.Cmd
+	if opts.smokeTestOnHost {
+		cmd = exec.Command("sh", "-c", opts.smokeTest)
+	} else {
+		cmd = dockerCommand("exec", containerName, "sh", "-c", opts.smokeTest)
+	}
+	cmd.Stdout = commandOutput
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("smoke test %q failed: %v", opts.smokeTest, err)
+	}
+	return nil
+}
+
+// withoutRestartPolicy returns a copy of a run argv whose --restart policy
+// is replaced by "no", along with the original policy ("" if it had none).
+// The new container runs like this while it is being verified, so a crash
+// loop fails the check instead of being hidden by restarts. Only the flags
+// before the image are looked at; a --restart in the container's own
+// command belongs to the program it runs.
+func withoutRestartPolicy(args []string) ([]string, string) {
+	out := append([]string(nil), args...)
+	i := 0
+	for i < len(out) && out[i] != "run" {
+		i++
+	}
+	for i++; i+1 < len(out); i++ {
+		switch arg := out[i]; {
+		case !strings.HasPrefix(arg, "-"):
+			return out, ""
+		case arg == "--restart":
+			policy := out[i+1]
+			out[i+1] = "no"
+			return out, policy
+		case !runBoolFlags[arg]:
+			i++
+		}
+	}
+	return out, ""
+}
+
+// applyRestartPolicy sets the real restart policy once verification passed.
+func applyRestartPolicy(containerName, policy string) error {
+	if err := dockerCommand("update", "--restart", policy, containerName).Run(); err != nil {
+		return fmt.Errorf("failed to set restart policy %s: %v", policy, err)
+	}
+	return nil
+}
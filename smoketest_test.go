@@ -0,0 +1,125 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestSmokeTest(t *testing.T) {
+	const check = "curl -f localhost:8080/health"
+
+	t.Run("passing", func(t *testing.T) {
+		f, _ := setupFlow(t)
+		f.inspect("web", fixture(t, "many-flags"))
+		opts.smokeTest = check
+
+		if result := processOne("web"); result.Status != statusRestarted {
+			t.Fatalf("status = %s (%s), want %s", result.Status, result.Error, statusRestarted)
+		}
+		exec := f.index("docker", "exec", "web", "sh", "-c", check)
+		if exec < 0 || exec < f.index("docker", "run") {
+			t.Errorf("smoke test not run in the new container; calls:\n%s", f.dump())
+		}
+		f.call("docker", "rm", "web_drun_backup")
+	})
+
+	t.Run("failing rolls back", func(t *testing.T) {
+		f, _ := setupFlow(t)
+		f.inspect("web", fixture(t, "many-flags"))
+		f.answer("", "docker", "container", "inspect", "web")
+		f.fail("curl: (7) Failed to connect", "docker", "exec")
+		opts.smokeTest = check
+
+		result := processOne("web")
+		if result.Status != statusFailed || !strings.Contains(result.Error, "smoke test") {
+			t.Fatalf("result = %s %q, want the smoke test to fail", result.Status, result.Error)
+		}
+		rm, rename, start := f.index("docker", "rm", "-f", "web"), f.index("docker", "rename", "web_drun_backup", "web"), f.index("docker", "start", "web")
+		if !(f.index("docker", "exec") < rm && rm < rename && rename < start) {
+			t.Errorf("want the new container removed and the old one renamed back and started; calls:\n%s", f.dump())
+		}
+		if f.count("docker", "rm", "web_drun_backup") > 0 {
+			t.Error("the backup was removed although the smoke test failed")
+		}
+	})
+
+	t.Run("on the host", func(t *testing.T) {
+		f, _ := setupFlow(t)
+		f.inspect("web", fixture(t, "many-flags"))
+		opts.smokeTest, opts.smokeTestOnHost = "exit 3", true
+
+		if result := processOne("web"); result.Status != statusFailed {
+			t.Fatalf("status = %s, want %s", result.Status, statusFailed)
+		}
+		if f.count("docker", "exec") > 0 {
+			t.Error("a host smoke test ran docker exec")
+		}
+	})
+}
+
+func TestWithoutRestartPolicy(t *testing.T) {
+	tests := []struct {
+		name       string
+		args       []string
+		wantArgs   []string
+		wantPolicy string
+	}{
+		{"policy", []string{"docker", "run", "-d", "--restart", "always", "nginx"},
+			[]string{"docker", "run", "-d", "--restart", "no", "nginx"}, "always"},
+		{"remote host", []string{"docker", "-H", "ssh://h", "run", "--name", "web", "--restart", "on-failure:3", "nginx"},
+			[]string{"docker", "-H", "ssh://h", "run", "--name", "web", "--restart", "no", "nginx"}, "on-failure:3"},
+		{"only in the command", []string{"docker", "run", "-d", "--name", "web", "app", "--restart", "always"},
+			[]string{"docker", "run", "-d", "--name", "web", "app", "--restart", "always"}, ""},
+		{"as a flag value", []string{"docker", "run", "-e", "--restart", "app"},
+			[]string{"docker", "run", "-e", "--restart", "app"}, ""},
+		{"none", []string{"docker", "run", "--read-only", "nginx"},
+			[]string{"docker", "run", "--read-only", "nginx"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args, policy := withoutRestartPolicy(tt.args)
+			if !slices.Equal(args, tt.wantArgs) || policy != tt.wantPolicy {
+				t.Errorf("withoutRestartPolicy = %q, %q; want %q, %q", args, policy, tt.wantArgs, tt.wantPolicy)
+			}
+		})
+	}
+}
+
+func TestRestartPolicyAfterVerification(t *testing.T) {
+	t.Run("held until the smoke test passed", func(t *testing.T) {
+		f, _ := setupFlow(t)
+		f.inspect("web", fixture(t, "many-flags"))
+		opts.smokeTest = "true"
+
+		if result := processOne("web"); result.Status != statusRestarted {
+			t.Fatalf("status = %s (%s), want %s", result.Status, result.Error, statusRestarted)
+		}
+		if got := flagValues(f.call("docker", "run"), "--restart"); !slices.Equal(got, []string{"no"}) {
+			t.Errorf("run --restart %q, want no while verifying", got)
+		}
+		update := f.index("docker", "update", "--restart", "on-failure:3", "web")
+		if update < f.index("docker", "exec") {
+			t.Errorf("restart policy not applied after the smoke test; calls:\n%s", f.dump())
+		}
+	})
+
+	t.Run("a --restart in the command is left alone", func(t *testing.T) {
+		f, _ := setupFlow(t)
+		raw := strings.Replace(fixture(t, "many-flags"), `{"Name": "on-failure", "MaximumRetryCount": 3}`, `{"Name": "no"}`, 1)
+		raw = strings.Replace(raw, `"Cmd": ["nginx", "-g", "daemon off;"]`, `"Cmd": ["supervise", "--restart", "always"]`, 1)
+		f.inspect("web", raw)
+		opts.smokeTest = "true"
+
+		if result := processOne("web"); result.Status != statusRestarted {
+			t.Fatalf("status = %s (%s), want %s", result.Status, result.Error, statusRestarted)
+		}
+		run := f.call("docker", "run")
+		if !slices.Equal(run[len(run)-3:], []string{"supervise", "--restart", "always"}) {
+			t.Errorf("the command was changed: %q", run)
+		}
+		if f.count("docker", "update") > 0 {
+			t.Errorf("a restart policy was applied to a container without one; calls:\n%s", f.dump())
+		}
+	})
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// specSchemaVersion identifies the layout of the --output spec document.
+// Bump it whenever a field changes meaning or is removed.
+const specSchemaVersion = "drun/run-spec/v1"
+
+// runSpec is a runtime-neutral description of how to run a container,
+// independent of docker's inspect format.
+type runSpec struct {
+	Name       string        `json:"name"`
+	Image      string        `json:"image"`
+	Command    []string      `json:"command,omitempty"`
+	Env        []string      `json:"env,omitempty"`
+	Ports      []specPort    `json:"ports,omitempty"`
+	Volumes    []specVolume  `json:"volumes,omitempty"`
+	Networks   []string      `json:"networks,omitempty"`
+	Restart    string        `json:"restart,omitempty"`
+	Resources  specResources `json:"resources"`
+	Privileged bool          `json:"privileged,omitempty"`
+	Argv       []string      `json:"argv"`
+}
+
+type specPort struct {
+	HostIP        string `json:"hostIp,omitempty"`
+	HostPort      string `json:"hostPort"`
+	ContainerPort string `json:"containerPort"`
+	Protocol      string `json:"protocol"`
+}
+
+type specVolume struct {
+	Source   string `json:"source"`
+	Target   string `json:"target"`
+	ReadOnly bool   `json:"readOnly,omitempty"`
+}
+
+type specResources struct {
+	CPUs      string `json:"cpus,omitempty"`
+	CPUQuota  int64  `json:"cpuQuota,omitempty"`
+	CPUPeriod int64  `json:"cpuPeriod,omitempty"`
+	CPUShares int64  `json:"cpuShares,omitempty"`
+	// Memory and MemorySwap are in bytes; a MemorySwap of -1 means
+	// unlimited swap.
+	Memory     int64 `json:"memory,omitempty"`
+	MemorySwap int64 `json:"memorySwap,omitempty"`
+}
+
+type specDocument struct {
+	SchemaVersion string    `json:"schemaVersion"`
+	Containers    []runSpec `json:"containers"`
+}
+
+// exportSpec prints the run spec document for the given containers.
+func exportSpec(containerNames []string) error {
+	doc := specDocument{SchemaVersion: specSchemaVersion, Containers: []runSpec{}}
+	for _, name := range containerNames {
+		info, err := getContainerInfo(name)
+		if err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+		doc.Containers = append(doc.Containers, newRunSpec(info))
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(doc)
+}
+
+// exportArgv prints the generated docker run argv of each container as a
+// JSON array of strings, one per line, for callers that exec it themselves.
+func exportArgv(containerNames []string) error {
+	enc := json.NewEncoder(os.Stdout)
+	for _, name := range containerNames {
+		info, err := getContainerInfo(name)
+		if err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+		if err := enc.Encode(buildRunArgs(info)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func newRunSpec(info *ContainerInfo) runSpec {
+	hc := info.HostConfig
+	spec := runSpec{
+		Name:       strings.TrimPrefix(info.Name, "/"),
+		Image:      targetImage(info),
+		Command:    info.Config.Cmd,
+		Restart:    restartPolicyFlag(hc.RestartPolicy),
+		Privileged: hc.Privileged,
+		Argv:       buildRunArgs(info),
+	}
+
+	for _, env := range info.Config.Env {
+		if !shouldSkipEnv(env) {
+			spec.Env = append(spec.Env, env)
+		}
+	}
+
+	// Same order as the -p flags of the run command.
+	for _, port := range sortedPorts(hc.PortBindings) {
+		bindings := hc.PortBindings[port]
+		containerPort, protocol, _ := strings.Cut(port, "/")
+		if protocol == "" {
+			protocol = "tcp"
+		}
+		for _, binding := range bindings {
+			if binding.HostPort != "" {
+				spec.Ports = append(spec.Ports, specPort{
+					HostIP:        binding.HostIP,
+					HostPort:      binding.HostPort,
+					ContainerPort: containerPort,
+					Protocol:      protocol,
+				})
+			}
+		}
+	}
+
+	for _, bind := range volumeFlags(info) {
+		parts := strings.Split(bind, ":")
+		if len(parts) < 2 {
+			continue
+		}
+		volume := specVolume{Source: parts[0], Target: parts[1]}
+		if len(parts) > 2 {
+			for _, option := range strings.Split(parts[2], ",") {
+				volume.ReadOnly = volume.ReadOnly || option == "ro"
+			}
+		}
+		spec.Volumes = append(spec.Volumes, volume)
+	}
+
+	for name := range info.NetworkSettings.Networks {
+		spec.Networks = append(spec.Networks, name)
+	}
+	sort.Strings(spec.Networks)
+
+	if hc.NanoCpus > 0 {
+		spec.Resources.CPUs = formatCPUs(hc.NanoCpus)
+	} else {
+		spec.Resources.CPUQuota, spec.Resources.CPUPeriod = hc.CpuQuota, hc.CpuPeriod
+	}
+	spec.Resources.CPUShares = hc.CpuShares
+	if hc.Memory > 0 {
+		spec.Resources.Memory = hc.Memory
+		if _, ok := memorySwapFlag(hc.Memory, hc.MemorySwap); ok {
+			spec.Resources.MemorySwap = *hc.MemorySwap
+		}
+	}
+
+	return spec
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"encoding/json"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestExportSpecGolden(t *testing.T) {
+	resetState(t)
+	f := newFakeDocker(t)
+	f.inspect("web", fixture(t, "many-flags"))
+
+	out := captureStdout(t, func() {
+		if err := exportSpec([]string{"web"}); err != nil {
+			t.Fatal(err)
+		}
+	})
+	checkGolden(t, "spec.golden", out)
+
+	var doc specDocument
+	if err := json.Unmarshal([]byte(out), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.SchemaVersion != specSchemaVersion || len(doc.Containers) != 1 {
+		t.Errorf("document = %s with %d containers", doc.SchemaVersion, len(doc.Containers))
+	}
+}
+
+func TestExportArgv(t *testing.T) {
+	f, _ := setupFlow(t)
+	f.inspect("web", fixture(t, "many-flags"))
+	f.inspect("app", `{"Id":"abc123","Name":"/app","Image":"sha256:old","Config":{"Image":"busybox:1.36","Env":["GREETING=hello world"]},"HostConfig":{}}`)
+
+	var err error
+	out := captureStdout(t, func() { err = exportArgv([]string{"web", "app"}) })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// One JSON array per line, so a caller can exec each without a shell.
+	lines := strings.Split(strings.TrimSuffix(out, "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("%d lines, want one per container:\n%s", len(lines), out)
+	}
+	want := [][]string{manyFlagsArgv, {"docker", "run", "-d", "--name", "app", "-e", "GREETING=hello world", "busybox:1.36"}}
+	for i, line := range lines {
+		var argv []string
+		if err := json.Unmarshal([]byte(line), &argv); err != nil {
+			t.Fatalf("line %d is not a JSON array: %v\n%s", i+1, err, line)
+		}
+		if !slices.Equal(argv, want[i]) {
+			t.Errorf("line %d:\ngot  %q\nwant %q", i+1, argv, want[i])
+		}
+	}
+}
+
+func TestRunSpecPortsAndMemory(t *testing.T) {
+	resetState(t)
+	info := testContainer(t, "", `"PortBindings":{"8080/tcp":[{"HostPort":"18080"}],"80/tcp":[{"HostPort":"8000"}],"443/udp":[{"HostPort":"443"}]},
+		"Memory":268435456,"MemorySwap":1073741824`)
+
+	spec := newRunSpec(info)
+	var ports []string
+	for _, port := range spec.Ports {
+		ports = append(ports, port.ContainerPort+"/"+port.Protocol)
+	}
+	if want := []string{"80/tcp", "443/udp", "8080/tcp"}; !slices.Equal(ports, want) {
+		t.Errorf("ports %q, want %q in the order of the -p flags", ports, want)
+	}
+	if spec.Resources.Memory != 268435456 || spec.Resources.MemorySwap != 1073741824 {
+		t.Errorf("resources = %+v, want memory 256m and memory swap 1g", spec.Resources)
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// containerState is what drun remembers about the last successful recreate
+// of a container, keyed by container name in the state file.
+type containerState struct {
+	Image     string    `json:"image"`
+	ImageID   string    `json:"image_id,omitempty"`
+	Digest    string    `json:"digest,omitempty"`
+	Argv      []string  `json:"argv"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	// Pending holds the inspect output of a container that was moved aside
+	// but not yet recreated, so an interrupted run can be retried.
+	Pending json.RawMessage `json:"pending,omitempty"`
+}
+
+func defaultHistoryPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ".drun_history"
+	}
+	return filepath.Join(home, ".drun_history")
+}
+
+// appendHistory records a command line that was run, prefixed with the
+// time it ran, in the --record-command history file.
+func appendHistory(path, command string) error {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(f, "%s %s\n", time.Now().UTC().Format(time.RFC3339), command); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func defaultStatePath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ".drun_state.json"
+	}
+	return filepath.Join(home, ".drun_state.json")
+}
+
+// loadState reads the state file. A missing file is an empty state.
+func loadState(path string) (map[string]containerState, error) {
+	state := make(map[string]containerState)
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+func saveState(path string, state map[string]containerState) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o600)
+}
+
+func recordState(path, containerName string, entry containerState) error {
+	state, err := loadState(path)
+	if err != nil {
+		return err
+	}
+	state[containerName] = entry
+	return saveState(path, state)
+}
+
+// recordPending saves the inspect output of a container that is about to be
+// replaced. The entry is cleared when recordState stores the successful run,
+// or by clearPending when the old container is kept after all.
+func recordPending(path, containerName string, raw json.RawMessage) error {
+	state, err := loadState(path)
+	if err != nil {
+		return err
+	}
+	entry := state[containerName]
+	entry.Pending = raw
+	return recordState(path, containerName, entry)
+}
+
+// clearPending drops the configuration saved by recordPending once the old
+// container is back under its name, so a later run does not mistake it for
+// an interrupted recreate. An entry that holds nothing else is removed.
+func clearPending(path, containerName string) error {
+	state, err := loadState(path)
+	if err != nil {
+		return err
+	}
+	entry, ok := state[containerName]
+	if !ok || len(entry.Pending) == 0 {
+		return nil
+	}
+	entry.Pending = nil
+	if entry.Argv == nil {
+		delete(state, containerName)
+	} else {
+		state[containerName] = entry
+	}
+	return saveState(path, state)
+}
+
+// pendingContainerInfo returns the configuration saved by recordPending, or
+// nil if the last run of that container was not interrupted.
+func pendingContainerInfo(path, containerName string) (*ContainerInfo, error) {
+	state, err := loadState(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read state file: %v", err)
+	}
+	entry, ok := state[containerName]
+	if !ok || len(entry.Pending) == 0 {
+		return nil, nil
+	}
+	return parseContainerInfo(entry.Pending)
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// pendingFor reports whether the state file still holds a configuration
+// to resume name from.
+func pendingFor(t *testing.T, name string) bool {
+	t.Helper()
+	state, err := loadState(opts.stateFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return len(state[name].Pending) > 0
+}
+
+func TestPendingClearedWhenOldContainerKept(t *testing.T) {
+	tests := []struct {
+		name   string
+		script func(f *fakeDocker)
+		want   string
+	}{
+		{"run fails", func(f *fakeDocker) { f.fail("boom", "docker", "run") }, statusFailed},
+		{"stop fails", func(f *fakeDocker) { f.fail("boom", "docker", "stop") }, statusFailed},
+		{"rename fails", func(f *fakeDocker) { f.fail("boom", "docker", "rename", "web") }, statusFailed},
+		{"cancelled", func(f *fakeDocker) {
+			opts.yes = false
+			stdinReader.Reset(strings.NewReader("n\n"))
+		}, statusCancelled},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, _ := setupFlow(t)
+			f.inspect("web", fixture(t, "many-flags"))
+			f.answer("", "docker", "container", "inspect", "web")
+			tt.script(f)
+
+			if result := processOne("web"); result.Status != tt.want {
+				t.Fatalf("status = %s (%s), want %s", result.Status, result.Error, tt.want)
+			}
+			if pendingFor(t, "web") {
+				t.Error("the state file still holds a pending config for a container that was kept")
+			}
+		})
+	}
+}
+
+func TestPendingKeptWhileContainerGone(t *testing.T) {
+	f, _ := setupFlow(t)
+	f.fail("Error: No such object: web", "docker", "inspect", "web")
+	f.fail("boom", "docker", "run")
+	if err := recordPending(opts.stateFile, "web", json.RawMessage(fixture(t, "many-flags"))); err != nil {
+		t.Fatal(err)
+	}
+
+	if result := processOne("web"); result.Status != statusFailed {
+		t.Fatalf("status = %s (%s), want %s", result.Status, result.Error, statusFailed)
+	}
+	if !pendingFor(t, "web") {
+		t.Error("the pending config was dropped while the container is still gone")
+	}
+
+	// The retry gets through and records the run in its place.
+	f.answer("", "docker", "run")
+	if result := processOne("web"); result.Status != statusRestarted {
+		t.Fatalf("retry status = %s (%s), want %s", result.Status, result.Error, statusRestarted)
+	}
+	if pendingFor(t, "web") {
+		t.Error("the pending config survived the successful retry")
+	}
+}
+
+func TestClearPendingKeepsLastRun(t *testing.T) {
+	resetState(t)
+	path := opts.stateFile
+	if err := recordState(path, "web", containerState{Image: "nginx:1.25", Argv: []string{"docker", "run", "nginx:1.25"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := recordPending(path, "web", json.RawMessage(`{"Name":"/web"}`)); err != nil {
+		t.Fatal(err)
+	}
+	if err := recordPending(path, "db", json.RawMessage(`{"Name":"/db"}`)); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"web", "db", "cache"} {
+		if err := clearPending(path, name); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	state, err := loadState(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if web, ok := state["web"]; !ok || web.Pending != nil || web.Image != "nginx:1.25" {
+		t.Errorf("web = %+v, want the last run without the pending config", web)
+	}
+	if _, ok := state["db"]; ok {
+		t.Error("db entry kept although it only held a pending config")
+	}
+}
+
+func TestRecordCommandHistory(t *testing.T) {
+	f, _ := setupFlow(t)
+	f.inspect("web", fixture(t, "many-flags"))
+	opts.recordCommand = true
+	path := defaultHistoryPath()
+	if err := os.WriteFile(path, []byte("2026-01-01T00:00:00Z docker run -d --name old busybox\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if result := processOne("web"); result.Status != statusRestarted {
+			t.Fatalf("run %d: status = %s (%s), want %s", i+1, result.Status, result.Error, statusRestarted)
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	if len(lines) != 3 || lines[0] != "2026-01-01T00:00:00Z docker run -d --name old busybox" {
+		t.Fatalf("history is not the old entry plus one line per run:\n%s", data)
+	}
+	for _, line := range lines[1:] {
+		stamp, command, _ := strings.Cut(line, " ")
+		if _, err := time.Parse(time.RFC3339, stamp); err != nil {
+			t.Errorf("history line %q does not start with a timestamp: %v", line, err)
+		}
+		if command != formatCommand(manyFlagsArgv) {
+			t.Errorf("recorded %q, want %q", command, formatCommand(manyFlagsArgv))
+		}
+	}
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// ignoredInspectFields are populated by the daemon for every container and
+// carry nothing a user asked for, so they never count as dropped.
+var ignoredInspectFields = map[string]bool{
+	"Config.AttachStdout":      true,
+	"Config.AttachStderr":      true,
+	"Config.StdinOnce":         true,
+	"HostConfig.ConsoleSize":   true,
+	"HostConfig.MaskedPaths":   true,
+	"HostConfig.ReadonlyPaths": true,
+}
+
+// hostConfigDefaults lists the compact JSON the daemon reports for
+// HostConfig fields the user did not set.
+var hostConfigDefaults = map[string][]string{
+	"ShmSize":      {"67108864"},
+	"IpcMode":      {`"private"`, `"shareable"`},
+	"CgroupnsMode": {`"private"`, `"host"`},
+	"Runtime":      {`"runc"`},
+}
+
+// unmodeledFields lists the non-empty Config and HostConfig fields of a
+// container that ContainerInfo does not model, i.e. the settings a recreate
+// would silently drop. Config values inherited unchanged from the image are
+// not reported since the new container inherits them again.
+func unmodeledFields(info *ContainerInfo) ([]string, error) {
+	var raw struct {
+		Config     map[string]json.RawMessage `json:"Config"`
+		HostConfig map[string]json.RawMessage `json:"HostConfig"`
+	}
+	if err := json.Unmarshal(info.raw, &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse inspect output: %v", err)
+	}
+
+	imageConfig, err := imageConfigFields(info.ImageID)
+	if err != nil {
+		return nil, err
+	}
+
+	var fields []string
+	known := jsonFieldNames(reflect.TypeOf(info.Config))
+	for name, value := range raw.Config {
+		// The port set lives in the keys; every value is an empty object.
+		if name == "ExposedPorts" {
+			if hasExtraExposedPorts(value, info.HostConfig.PortBindings, imageConfig[name]) {
+				fields = append(fields, "Config."+name)
+			}
+			continue
+		}
+		if known[name] || ignoredInspectFields["Config."+name] || isEmptyJSON(value) {
+			continue
+		}
+		if name == "Hostname" && len(info.ID) >= 12 && string(value) == `"`+info.ID[:12]+`"` {
+			continue
+		}
+		if inherited, ok := imageConfig[name]; ok && jsonEqual(value, inherited) {
+			continue
+		}
+		fields = append(fields, "Config."+name)
+	}
+
+	known = jsonFieldNames(reflect.TypeOf(info.HostConfig))
+	for name, value := range raw.HostConfig {
+		// Volume and bind mounts come back as -v from the top-level Mounts.
+		if name == "Mounts" {
+			if hasUnpreservedMounts(value) {
+				fields = append(fields, "HostConfig."+name)
+			}
+			continue
+		}
+		if known[name] || ignoredInspectFields["HostConfig."+name] || isEmptyJSON(value) {
+			continue
+		}
+		if isDefaultHostConfig(name, value) {
+			continue
+		}
+		fields = append(fields, "HostConfig."+name)
+	}
+
+	sort.Strings(fields)
+	return fields, nil
+}
+
+// hasExtraExposedPorts reports whether a container exposes ports that
+// neither its -p bindings nor its image account for, i.e. ones only
+// --expose would recreate. Publishing a port exposes it as well.
+func hasExtraExposedPorts(value json.RawMessage, bindings map[string][]Port, image json.RawMessage) bool {
+	var exposed, inherited map[string]json.RawMessage
+	if err := json.Unmarshal(value, &exposed); err != nil {
+		return true
+	}
+	if image != nil {
+		if err := json.Unmarshal(image, &inherited); err != nil {
+			return true
+		}
+	}
+	for port := range exposed {
+		if _, published := bindings[port]; published {
+			continue
+		}
+		if _, ok := inherited[port]; ok {
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+// hasUnpreservedMounts reports whether a container has --mount entries of a
+// type volumeFlags does not re-emit, such as tmpfs.
+func hasUnpreservedMounts(value json.RawMessage) bool {
+	var mounts []struct {
+		Type string `json:"Type"`
+	}
+	if err := json.Unmarshal(value, &mounts); err != nil {
+		return true
+	}
+	for _, mount := range mounts {
+		if mount.Type != "volume" && mount.Type != "bind" {
+			return true
+		}
+	}
+	return false
+}
+
+func imageConfigFields(imageID string) (map[string]json.RawMessage, error) {
+	output, err := dockerCommand("image", "inspect", "--format", "{{json .Config}}", imageID).Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect image %s: %v", imageID, err)
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(output, &fields); err != nil {
+		return nil, fmt.Errorf("failed to parse image config for %s: %v", imageID, err)
+	}
+	return fields, nil
+}
+
+func jsonFieldNames(t reflect.Type) map[string]bool {
+	names := make(map[string]bool, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if name != "" && name != "-" {
+			names[name] = true
+		}
+	}
+	return names
+}
+
+func isDefaultHostConfig(name string, value json.RawMessage) bool {
+	for _, def := range hostConfigDefaults[name] {
+		if jsonEqual(value, json.RawMessage(def)) {
+			return true
+		}
+	}
+	return false
+}
+
+// isEmptyJSON reports whether a value is null, false, zero, an empty string,
+// or a list/object made only of such values (e.g. ConsoleSize [0, 0]).
+func isEmptyJSON(value json.RawMessage) bool {
+	var v interface{}
+	if err := json.Unmarshal(value, &v); err != nil {
+		return false
+	}
+	return isEmptyValue(v)
+}
+
+func isEmptyValue(v interface{}) bool {
+	switch v := v.(type) {
+	case nil:
+		return true
+	case bool:
+		return !v
+	case float64:
+		return v == 0
+	case string:
+		return v == ""
+	case []interface{}:
+		for _, item := range v {
+			if !isEmptyValue(item) {
+				return false
+			}
+		}
+		return true
+	case map[string]interface{}:
+		for _, item := range v {
+			if !isEmptyValue(item) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+func jsonEqual(a, b json.RawMessage) bool {
+	var ca, cb bytes.Buffer
+	if json.Compact(&ca, a) != nil || json.Compact(&cb, b) != nil {
+		return false
+	}
+	return bytes.Equal(ca.Bytes(), cb.Bytes())
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestUnmodeledExposedPorts(t *testing.T) {
+	tests := []struct {
+		name     string
+		exposed  string
+		bindings string
+		image    string
+		want     []string
+	}{
+		{"published with -p", `{"80/tcp":{}}`, `{"80/tcp":[{"HostIp":"","HostPort":"8080"}]}`, `{}`, nil},
+		{"published without a host port", `{"80/tcp":{}}`, `{"80/tcp":[{"HostIp":"","HostPort":""}]}`, `{}`, nil},
+		{"exposed by the image", `{"80/tcp":{}}`, `{}`, `{"ExposedPorts":{"80/tcp":{}}}`, nil},
+		{"image and -p together", `{"80/tcp":{},"443/tcp":{}}`, `{"443/tcp":[{"HostIp":"","HostPort":"8443"}]}`, `{"ExposedPorts":{"80/tcp":{}}}`, nil},
+		{"only --expose", `{"9000/tcp":{}}`, `{}`, `{"ExposedPorts":{"80/tcp":{}}}`, []string{"Config.ExposedPorts"}},
+		{"--expose next to -p", `{"80/tcp":{},"9000/tcp":{}}`, `{"80/tcp":[{"HostIp":"","HostPort":"8080"}]}`, `{}`, []string{"Config.ExposedPorts"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetState(t)
+			f := newFakeDocker(t)
+			f.answer(tt.image, "docker", "image", "inspect", "--format", "{{json .Config}}", "sha256:old")
+			info := testContainer(t, `"ExposedPorts":`+tt.exposed, `"PortBindings":`+tt.bindings)
+
+			got, err := unmodeledFields(info)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("unmodeledFields = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnmodeledFieldsReportsUnknown(t *testing.T) {
+	resetState(t)
+	f := newFakeDocker(t)
+	f.answer(`{"StopSignal":"SIGTERM"}`, "docker", "image", "inspect", "--format", "{{json .Config}}", "sha256:old")
+	info := testContainer(t, `"StopSignal":"SIGQUIT","Domainname":""`, `"ShmSize":67108864,"PidMode":"host","IpcMode":"private"`)
+
+	got, err := unmodeledFields(info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"Config.StopSignal", "HostConfig.PidMode"}; !slices.Equal(got, want) {
+		t.Errorf("unmodeledFields = %q, want %q", got, want)
+	}
+}
+
+func TestUnmodeledMounts(t *testing.T) {
+	tests := []struct {
+		name   string
+		mounts string
+		want   []string
+	}{
+		{"volume and bind", `[{"Type":"volume","Source":"data","Target":"/data"},{"Type":"bind","Source":"/srv/conf","Target":"/etc/app","ReadOnly":true}]`, nil},
+		{"tmpfs", `[{"Type":"volume","Source":"data","Target":"/data"},{"Type":"tmpfs","Target":"/run"}]`, []string{"HostConfig.Mounts"}},
+		{"none", `[]`, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetState(t)
+			newFakeDocker(t)
+			info := testContainer(t, "", `"Mounts":`+tt.mounts)
+
+			got, err := unmodeledFields(info)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("unmodeledFields = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+)
+
+// Per-container outcomes reported in the run summary.
+const (
+	statusRestarted = "restarted"
+	statusPlanned   = "planned"
+	statusCancelled = "cancelled"
+	statusSkipped   = "skipped"
+	statusFailed    = "failed"
+)
+
+type containerResult struct {
+	Container  string   `json:"container"`
+	Image      string   `json:"image,omitempty"`
+	OldImageID string   `json:"old_image_id,omitempty"`
+	NewImageID string   `json:"new_image_id,omitempty"`
+	Argv       []string `json:"argv,omitempty"`
+	Status     string   `json:"status"`
+	Error      string   `json:"error,omitempty"`
+	Notes      []string `json:"notes,omitempty"`
+}
+
+// runSummary aggregates the results of every container handled in one
+// invocation. It is written once at the end by --summary-json.
+type runSummary struct {
+	Total     int               `json:"total"`
+	Succeeded int               `json:"succeeded"`
+	Skipped   int               `json:"skipped"`
+	Failed    int               `json:"failed"`
+	Success   bool              `json:"success"`
+	Results   []containerResult `json:"results"`
+}
+
+func newRunSummary(results []containerResult) runSummary {
+	summary := runSummary{Total: len(results), Results: results}
+	for _, result := range results {
+		switch result.Status {
+		case statusFailed:
+			summary.Failed++
+		case statusCancelled, statusSkipped:
+			summary.Skipped++
+		default:
+			summary.Succeeded++
+		}
+	}
+	summary.Success = summary.Failed == 0
+	return summary
+}
+
+// printSummary lists the outcome of each container after a run that
+// handled more than one.
+func printSummary(summary runSummary) {
+	fmt.Fprintln(messages)
+	printInfo("Summary: %d succeeded, %d skipped, %d failed\n", summary.Succeeded, summary.Skipped, summary.Failed)
+	w := tabwriter.NewWriter(messages, 0, 4, 2, ' ', 0)
+	for _, result := range summary.Results {
+		detail := result.Error
+		if detail == "" && len(result.Notes) > 0 {
+			detail = strings.Join(result.Notes, "; ")
+		}
+		fmt.Fprintf(w, "  %s\t%s\t%s\n", result.Container, result.Status, detail)
+	}
+	w.Flush()
+}
+
+func writeSummaryJSON(path string, summary runSummary) error {
+	data, err := json.MarshalIndent(summary, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	if path == "-" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// printJSONReport is the stdout of a --json run: the result object of the
+// container, or the whole summary when several were handled.
+func printJSONReport(summary runSummary) error {
+	var report any = summary
+	if len(summary.Results) == 1 {
+		report = summary.Results[0]
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(report)
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSummaryJSONThreeContainers(t *testing.T) {
+	f, _ := setupFlow(t)
+	f.inspect("web", fixture(t, "many-flags"))
+	f.inspect("cache", strings.Replace(fixture(t, "many-flags"), `"sha256:old"`, `"sha256:new"`, 1))
+	f.fail("Error: No such object: db", "docker", "inspect", "db")
+	opts.summaryJSON = filepath.Join(t.TempDir(), "summary.json")
+
+	if code := runUpdate([]string{"web", "cache", "db"}); code != 1 {
+		t.Errorf("exit code = %d, want 1 for the failed container", code)
+	}
+
+	data, err := os.ReadFile(opts.summaryJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var summary runSummary
+	if err := json.Unmarshal(data, &summary); err != nil {
+		t.Fatalf("summary is not JSON: %v\n%s", err, data)
+	}
+	if summary.Total != 3 || summary.Succeeded != 1 || summary.Skipped != 1 || summary.Failed != 1 || summary.Success {
+		t.Errorf("totals = %+v, want 3 total, 1 succeeded, 1 skipped, 1 failed, no success", summary)
+	}
+
+	want := []struct{ container, status string }{
+		{"web", statusRestarted},
+		{"cache", statusSkipped},
+		{"db", statusFailed},
+	}
+	if len(summary.Results) != len(want) {
+		t.Fatalf("%d results, want %d: %s", len(summary.Results), len(want), data)
+	}
+	for i, w := range want {
+		result := summary.Results[i]
+		if result.Container != w.container || result.Status != w.status {
+			t.Errorf("result %d = %s %s, want %s %s", i, result.Container, result.Status, w.container, w.status)
+		}
+	}
+	if web := summary.Results[0]; web.OldImageID != "sha256:old" || web.NewImageID != "sha256:new" || len(web.Argv) == 0 {
+		t.Errorf("web result = %+v, want image ids and argv", web)
+	}
+	if db := summary.Results[2]; db.Error == "" {
+		t.Error("db result has no error")
+	}
+}
+
+func TestNewRunSummary(t *testing.T) {
+	tests := []struct {
+		statuses                   []string
+		succeeded, skipped, failed int
+	}{
+		{nil, 0, 0, 0},
+		{[]string{statusRestarted, statusPlanned}, 2, 0, 0},
+		{[]string{statusCancelled, statusSkipped, statusRestarted}, 1, 2, 0},
+		{[]string{statusFailed, statusRestarted}, 1, 0, 1},
+	}
+	for _, tt := range tests {
+		var results []containerResult
+		for _, status := range tt.statuses {
+			results = append(results, containerResult{Container: "c", Status: status})
+		}
+		summary := newRunSummary(results)
+		if summary.Total != len(results) || summary.Succeeded != tt.succeeded || summary.Skipped != tt.skipped || summary.Failed != tt.failed {
+			t.Errorf("%q: got %+v", tt.statuses, summary)
+		}
+		if summary.Success != (tt.failed == 0) {
+			t.Errorf("%q: success = %v", tt.statuses, summary.Success)
+		}
+	}
+}
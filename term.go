@@ -0,0 +1,36 @@
+package main
+
+import (
+	"io"
+	"os"
+)
+
+// isTerminal reports whether f is attached to a terminal rather than a pipe
+// or a file.
+func isTerminal(f *os.File) bool {
+	stat, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return stat.Mode()&os.ModeCharDevice != 0
+}
+
+// colorEnabled reports whether color codes should be written to w: only to
+// a terminal, and never with --no-color or a non-empty NO_COLOR (see
+// https://no-color.org).
+func colorEnabled(w io.Writer) bool {
+	if opts.noColor || os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	f, ok := w.(*os.File)
+	return ok && isTerminal(f)
+}
+
+// paint wraps text in a color code when w accepts colors, and returns it
+// unchanged otherwise.
+func paint(w io.Writer, color, text string) string {
+	if !colorEnabled(w) {
+		return text
+	}
+	return color + text + ColorReset
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// exportTerraform prints one docker_container resource (kreuzwerker/docker
+// provider) per container, ready to be used with `terraform import`.
+func exportTerraform(containerNames []string) error {
+	var specs []runSpec
+	for _, name := range containerNames {
+		info, err := getContainerInfo(name)
+		if err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+		specs = append(specs, newRunSpec(info))
+	}
+	return writeTerraform(os.Stdout, specs)
+}
+
+func writeTerraform(w io.Writer, specs []runSpec) error {
+	var b strings.Builder
+	for i, spec := range specs {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		writeTerraformResource(&b, spec)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func writeTerraformResource(b *strings.Builder, spec runSpec) {
+	fmt.Fprintf(b, "resource \"docker_container\" %s {\n", hclString(terraformName(spec.Name)))
+
+	attrs := [][2]string{
+		{"name", hclString(spec.Name)},
+		{"image", hclString(spec.Image)},
+	}
+	// The provider splits the retry count into its own attribute.
+	if spec.Restart != "" {
+		policy, retries, _ := strings.Cut(spec.Restart, ":")
+		attrs = append(attrs, [2]string{"restart", hclString(policy)})
+		if retries != "" {
+			attrs = append(attrs, [2]string{"max_retry_count", retries})
+		}
+	}
+	if len(spec.Command) > 0 {
+		attrs = append(attrs, [2]string{"command", hclList(spec.Command)})
+	}
+	if len(spec.Env) > 0 {
+		attrs = append(attrs, [2]string{"env", hclList(spec.Env)})
+	}
+	writeHCLAttrs(b, "  ", attrs)
+
+	for _, port := range spec.Ports {
+		// internal and external are single numbers in the provider.
+		if strings.Contains(port.ContainerPort, "-") || strings.Contains(port.HostPort, "-") {
+			printWarning("%s publishes the port range %s:%s/%s, which the terraform provider cannot express; leaving it out\n", spec.Name, port.HostPort, port.ContainerPort, port.Protocol)
+			continue
+		}
+		attrs := [][2]string{
+			{"internal", port.ContainerPort},
+			{"external", port.HostPort},
+		}
+		if port.HostIP != "" {
+			attrs = append(attrs, [2]string{"ip", hclString(port.HostIP)})
+		}
+		attrs = append(attrs, [2]string{"protocol", hclString(port.Protocol)})
+		writeHCLBlock(b, "ports", attrs)
+	}
+
+	// Absolute sources are bind mounts, anything else is a named volume.
+	for _, volume := range spec.Volumes {
+		var attrs [][2]string
+		if strings.HasPrefix(volume.Source, "/") {
+			attrs = append(attrs, [2]string{"host_path", hclString(volume.Source)})
+		} else {
+			attrs = append(attrs, [2]string{"volume_name", hclString(volume.Source)})
+		}
+		attrs = append(attrs, [2]string{"container_path", hclString(volume.Target)})
+		if volume.ReadOnly {
+			attrs = append(attrs, [2]string{"read_only", "true"})
+		}
+		writeHCLBlock(b, "volumes", attrs)
+	}
+
+	b.WriteString("}\n")
+}
+
+func writeHCLBlock(b *strings.Builder, name string, attrs [][2]string) {
+	fmt.Fprintf(b, "\n  %s {\n", name)
+	writeHCLAttrs(b, "    ", attrs)
+	b.WriteString("  }\n")
+}
+
+// writeHCLAttrs writes key = value lines with the equals signs aligned the
+// way terraform fmt does, so the output needs no reformatting.
+func writeHCLAttrs(b *strings.Builder, indent string, attrs [][2]string) {
+	width := 0
+	for _, attr := range attrs {
+		width = max(width, len(attr[0]))
+	}
+	for _, attr := range attrs {
+		fmt.Fprintf(b, "%s%-*s = %s\n", indent, width, attr[0], attr[1])
+	}
+}
+
+var terraformInvalidChars = regexp.MustCompile(`[^A-Za-z0-9_-]`)
+
+// terraformName turns a container name into a valid resource name, which
+// may only contain letters, digits, underscores and dashes and must not
+// start with a digit or dash.
+func terraformName(name string) string {
+	name = terraformInvalidChars.ReplaceAllString(name, "_")
+	if name == "" || !(name[0] == '_' || name[0] >= 'A' && name[0] <= 'Z' || name[0] >= 'a' && name[0] <= 'z') {
+		name = "_" + name
+	}
+	return name
+}
+
+// hclString quotes a string for HCL. Go's quoting covers the escapes, but
+// HCL also interpolates ${ and %{ inside strings, so those are doubled.
+func hclString(s string) string {
+	quoted := strconv.Quote(s)
+	quoted = strings.ReplaceAll(quoted, "${", "$${")
+	return strings.ReplaceAll(quoted, "%{", "%%{")
+}
+
+func hclList(items []string) string {
+	quoted := make([]string, len(items))
+	for i, item := range items {
+		quoted[i] = hclString(item)
+	}
+	return "[" + strings.Join(quoted, ", ") + "]"
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTerraformGolden(t *testing.T) {
+	resetState(t)
+	info := parseInfo(t, fixture(t, "many-flags"))
+
+	var b strings.Builder
+	if err := writeTerraform(&b, []runSpec{newRunSpec(info)}); err != nil {
+		t.Fatal(err)
+	}
+	checkGolden(t, "terraform.golden", b.String())
+}
+
+func TestHCLString(t *testing.T) {
+	tests := map[string]string{
+		"plain":       `"plain"`,
+		`say "hi"`:    `"say \"hi\""`,
+		"${var.x}":    `"$${var.x}"`,
+		"%{if true}":  `"%%{if true}"`,
+		"cost: $5":    `"cost: $5"`,
+		"line\nbreak": `"line\nbreak"`,
+	}
+	for in, want := range tests {
+		if got := hclString(in); got != want {
+			t.Errorf("hclString(%q) = %s, want %s", in, got, want)
+		}
+	}
+}
+
+func TestTerraformSkipsPortRanges(t *testing.T) {
+	messages := resetState(t)
+	spec := runSpec{Name: "web", Image: "nginx:1.25", Ports: []specPort{
+		{HostPort: "8080", ContainerPort: "80", Protocol: "tcp"},
+		{HostPort: "8000-8010", ContainerPort: "8000-8010", Protocol: "tcp"},
+		{HostPort: "9000-9001", ContainerPort: "9000", Protocol: "udp"},
+	}}
+
+	var b strings.Builder
+	if err := writeTerraform(&b, []runSpec{spec}); err != nil {
+		t.Fatal(err)
+	}
+	out := b.String()
+	if strings.Count(out, "ports {") != 1 || !strings.Contains(out, "internal = 80") {
+		t.Errorf("want only the single port 80 exported:\n%s", out)
+	}
+	if strings.Contains(out, "8000") || strings.Contains(out, "9000") {
+		t.Errorf("port range written to HCL:\n%s", out)
+	}
+	for _, want := range []string{"8000-8010:8000-8010/tcp", "9000-9001:9000/udp"} {
+		if !strings.Contains(messages.String(), want) {
+			t.Errorf("no warning for the range %s: %q", want, messages)
+		}
+	}
+}
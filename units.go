@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var sizePattern = regexp.MustCompile(`(?i)^(\d+(?:\.\d+)?)\s*([kmgtp]?)(i?b)?$`)
+
+// parseSize parses a human size such as "64m", "1.5g" or "512MiB" into
+// bytes. Units are binary (k = 1024), matching how docker reads memory and
+// tmpfs sizes.
+func parseSize(s string) (int64, error) {
+	m := sizePattern.FindStringSubmatch(strings.TrimSpace(s))
+	if m == nil {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+
+	value, err := strconv.ParseFloat(m[1], 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	multiplier := int64(1)
+	if unit := strings.ToLower(m[2]); unit != "" {
+		multiplier = 1 << (10 * (strings.Index("kmgtp", unit) + 1))
+	}
+	return int64(value * float64(multiplier)), nil
+}
+
+// formatSize renders bytes with the largest binary unit docker accepts that
+// divides them exactly, e.g. 536870912 as "512m", so memory limits read the
+// way they were likely written. Sizes that divide by no unit stay in bytes.
+func formatSize(bytes int64) string {
+	for i := len("kmgt"); i > 0; i-- {
+		if unit := int64(1) << (10 * i); bytes >= unit && bytes%unit == 0 {
+			return fmt.Sprintf("%d%c", bytes/unit, "kmgt"[i-1])
+		}
+	}
+	return fmt.Sprintf("%d", bytes)
+}
+
+// sizeFlag is a flag.Value holding a byte size given in human units.
+type sizeFlag int64
+
+func (s *sizeFlag) String() string {
+	return fmt.Sprintf("%d", int64(*s))
+}
+
+func (s *sizeFlag) Set(value string) error {
+	size, err := parseSize(value)
+	if err != nil {
+		return err
+	}
+	*s = sizeFlag(size)
+	return nil
+}